@@ -39,7 +39,7 @@ func loadAssets(fs billy.Filesystem) error {
 
 	var buf *bytes.Buffer
 
-	if config.DEV {
+	if config.DEV || config.LOCAL {
 		dir, err := patsy.Dir(vos.Os(), "github.com/sniperkit/snk.fork.dave-jsgo/assets")
 		if err != nil {
 			return err
@@ -0,0 +1,298 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/dave/services"
+	"github.com/dave/services/builder/buildermsg"
+	"github.com/dave/services/constor/constormsg"
+	"github.com/dave/services/getter/gettermsg"
+	"github.com/gorilla/websocket"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+	playmessages "github.com/sniperkit/snk.fork.dave-jsgo/server/play/messages"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/servermsg"
+	wasmmessages "github.com/sniperkit/snk.fork.dave-jsgo/server/wasm/messages"
+)
+
+// clientVersion is sent in the wasm DeployQuery.
+const clientVersion = "jsgo-cli-1"
+
+// deployPlay sends the source to the playground server, which downloads the remaining dependencies,
+// compiles and deploys.
+func deployPlay(ctx context.Context, url, dir string, tags []string, minify bool) error {
+	main, imports, source, err := getSource(dir)
+	if err != nil {
+		return err
+	}
+
+	c := &client{
+		marshal:   playmessages.Marshal,
+		unmarshal: playmessages.Unmarshal,
+	}
+	if err := c.dial(ctx, url); err != nil {
+		return err
+	}
+	defer c.close()
+
+	fmt.Fprintf(os.Stderr, "Deploying %s\n", main)
+
+	if err := c.send(playmessages.Deploy{
+		Main:     main,
+		Imports:  imports,
+		Source:   source,
+		Tags:     tags,
+		NoMinify: !minify,
+	}); err != nil {
+		return err
+	}
+
+	for {
+		m, err := c.receive()
+		if err != nil {
+			return err
+		}
+		switch m := m.(type) {
+		case playmessages.DeployComplete:
+			fmt.Printf("Index: %s://%s/%s\n", config.Protocol[config.Index], config.Host[config.Index], m.Index)
+			fmt.Printf("Loader: %s://%s/%s.js\n", config.Protocol[config.Pkg], config.Host[config.Pkg], m.Main)
+			return nil
+		}
+	}
+}
+
+// deployWasm builds the package in dir with GOOS=js GOARCH=wasm, and deploys the output to the wasm
+// server. Files that are already stored on the server aren't sent.
+func deployWasm(ctx context.Context, url, dir string, tags []string) error {
+	files, err := buildWasm(dir, tags)
+	if err != nil {
+		return err
+	}
+
+	c := &client{
+		marshal:   wasmmessages.Marshal,
+		unmarshal: wasmmessages.Unmarshal,
+	}
+	if err := c.dial(ctx, url); err != nil {
+		return err
+	}
+	defer c.close()
+
+	var keys []wasmmessages.DeployFileKey
+	for _, f := range files {
+		keys = append(keys, f.DeployFileKey)
+	}
+	if err := c.send(wasmmessages.DeployQuery{Version: clientVersion, Files: keys}); err != nil {
+		return err
+	}
+
+	for {
+		m, err := c.receive()
+		if err != nil {
+			return err
+		}
+		switch m := m.(type) {
+		case wasmmessages.DeployClientVersionNotSupported:
+			return errors.New("this version of the jsgo client is not supported by the server")
+		case wasmmessages.DeployQueryResponse:
+			if len(m.Required) == 0 {
+				printWasm(files)
+				return nil
+			}
+			var payload wasmmessages.DeployPayload
+			for _, key := range m.Required {
+				for _, f := range files {
+					if f.DeployFileKey == key {
+						payload.Files = append(payload.Files, f)
+					}
+				}
+			}
+			if err := c.send(payload); err != nil {
+				return err
+			}
+		case wasmmessages.DeployDone:
+			printWasm(files)
+			return nil
+		}
+	}
+}
+
+func printWasm(files []wasmmessages.DeployFile) {
+	for _, f := range files {
+		switch f.Type {
+		case wasmmessages.DeployFileTypeIndex:
+			fmt.Printf("Index: %s://%s/%s\n", config.Protocol[config.Index], config.Host[config.Index], f.Hash)
+		case wasmmessages.DeployFileTypeLoader:
+			fmt.Printf("Loader: %s://%s/%s.js\n", config.Protocol[config.Pkg], config.Host[config.Pkg], f.Hash)
+		}
+	}
+}
+
+// buildWasm builds the package and returns the wasm binary, the loader JS and the index page.
+func buildWasm(dir string, tags []string) ([]wasmmessages.DeployFile, error) {
+	temp, err := ioutil.TempDir("", "jsgo")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(temp)
+
+	fmt.Fprintln(os.Stderr, "Building wasm")
+
+	out := filepath.Join(temp, "out.wasm")
+	cmd := exec.Command("go", "build", "-o", out, "-tags", strings.Join(tags, " "), ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOOS=js", "GOARCH=wasm")
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+	wasm, err := ioutil.ReadFile(out)
+	if err != nil {
+		return nil, err
+	}
+
+	goroot, err := exec.Command("go", "env", "GOROOT").Output()
+	if err != nil {
+		return nil, err
+	}
+	var wasmExec []byte
+	for _, name := range []string{"lib/wasm/wasm_exec.js", "misc/wasm/wasm_exec.js"} {
+		if wasmExec, err = ioutil.ReadFile(filepath.Join(strings.TrimSpace(string(goroot)), filepath.FromSlash(name))); err == nil {
+			break
+		}
+	}
+	if wasmExec == nil {
+		return nil, errors.New("can't find wasm_exec.js in GOROOT")
+	}
+
+	wasmFile := newDeployFile(wasmmessages.DeployFileTypeWasm, wasm)
+	loader := newDeployFile(wasmmessages.DeployFileTypeLoader, append(wasmExec, fmt.Sprintf(loaderTemplate, config.Protocol[config.Pkg], config.Host[config.Pkg], wasmFile.Hash)...))
+	index := newDeployFile(wasmmessages.DeployFileTypeIndex, []byte(fmt.Sprintf(indexTemplate, config.Protocol[config.Pkg], config.Host[config.Pkg], loader.Hash)))
+
+	return []wasmmessages.DeployFile{wasmFile, loader, index}, nil
+}
+
+func newDeployFile(typ wasmmessages.DeployFileType, contents []byte) wasmmessages.DeployFile {
+	return wasmmessages.DeployFile{
+		DeployFileKey: wasmmessages.DeployFileKey{
+			Type: typ,
+			Hash: fmt.Sprintf("%x", sha1.Sum(contents)),
+		},
+		Contents: contents,
+	}
+}
+
+const loaderTemplate = `
+(function() {
+	var go = new Go();
+	WebAssembly.instantiateStreaming(fetch("%s://%s/%s.wasm"), go.importObject).then(function(result) {
+		go.run(result.instance);
+	});
+})();
+`
+
+const indexTemplate = `<html>
+	<head>
+		<meta charset="utf-8">
+	</head>
+	<body>
+		<script src="%s://%s/%s.js"></script>
+	</body>
+</html>`
+
+// client sends and receives messages over a websocket, and prints progress messages.
+type client struct {
+	conn      *websocket.Conn
+	marshal   func(services.Message) ([]byte, int, error)
+	unmarshal func([]byte) (services.Message, error)
+	last      string
+}
+
+func (c *client) dial(ctx context.Context, url string) error {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, url, nil)
+	if err != nil {
+		return err
+	}
+	c.conn = conn
+	return nil
+}
+
+func (c *client) close() {
+	c.conn.Close()
+}
+
+func (c *client) send(m services.Message) error {
+	b, typ, err := c.marshal(m)
+	if err != nil {
+		return err
+	}
+	return c.conn.WriteMessage(typ, b)
+}
+
+// receive returns the next message. Progress messages are printed, and errors from the server are
+// returned as errors.
+func (c *client) receive() (services.Message, error) {
+	_, b, err := c.conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	m, err := c.unmarshal(b)
+	if err != nil {
+		return nil, err
+	}
+	if e, ok := m.(servermsg.Error); ok {
+		return nil, errors.New(e.Message)
+	}
+	if description := describe(m); description != "" && description != c.last {
+		c.last = description
+		fmt.Fprintln(os.Stderr, description)
+	}
+	return m, nil
+}
+
+// describe returns a description of progress messages, in the same way as the compile page. Returns ""
+// for other messages.
+func describe(m services.Message) string {
+	switch m := m.(type) {
+	case servermsg.Queueing:
+		if m.Done {
+			return "Queueing: done"
+		}
+		return fmt.Sprintf("Queueing: position %d", m.Position)
+	case gettermsg.Downloading:
+		return progress("Downloading", m.Starting, m.Done, m.Message)
+	case buildermsg.Building:
+		return progress("Building", m.Starting, m.Done, m.Message)
+	case constormsg.Storing:
+		if m.Done || m.Starting {
+			return progress("Storing", m.Starting, m.Done, "")
+		}
+		return fmt.Sprintf("Storing: %d finished, %d unchanged, %d remain", m.Finished, m.Unchanged, m.Remain)
+	}
+	return ""
+}
+
+func progress(name string, starting, done bool, message string) string {
+	switch {
+	case done:
+		return name + ": done"
+	case starting || message == "":
+		return name + ": starting"
+	default:
+		return name + ": " + message
+	}
+}
@@ -0,0 +1,82 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+// Command jsgo compiles and deploys a main package from a local directory using the jsgo servers, and
+// prints the URLs of the index page and loader JS.
+//
+// Usage:
+//
+//	jsgo [flags] [dir]
+//
+// GopherJS builds use the playground Deploy protocol: the source is sent to the server, which downloads
+// the remaining dependencies and compiles. With -wasm the package is built locally with GOOS=js
+// GOARCH=wasm and the output is deployed with the wasm DeployQuery protocol.
+//
+// Build with the "local" tag to use a server started in local mode by default.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+)
+
+var (
+	tagsFlag   = flag.String("tags", "", "build tags (space or comma separated)")
+	minifyFlag = flag.Bool("minify", true, "minify the JS (GopherJS builds only)")
+	serverFlag = flag.String("server", "", "url of the server (defaults to the playground server, or the wasm server with -wasm)")
+	wasmFlag   = flag.Bool("wasm", false, "build WebAssembly locally and deploy with the wasm protocol")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: jsgo [flags] [dir]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+
+	if err := run(context.Background(), dir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, dir string) error {
+	tags := strings.Fields(strings.Replace(*tagsFlag, ",", " ", -1))
+	server := *serverFlag
+	if *wasmFlag {
+		if server == "" {
+			server = config.Protocol[config.Wasm] + "://" + config.Host[config.Wasm]
+		}
+		return deployWasm(ctx, socketUrl(server, "/_wasm/"), dir, tags)
+	}
+	if server == "" {
+		server = config.Protocol[config.Play] + "://" + config.Host[config.Play]
+	}
+	return deployPlay(ctx, socketUrl(server, "/_play/"), dir, tags, *minifyFlag)
+}
+
+// socketUrl converts the server url to a websocket url.
+func socketUrl(server, path string) string {
+	server = strings.TrimSuffix(server, "/")
+	switch {
+	case strings.HasPrefix(server, "https://"):
+		server = "wss://" + strings.TrimPrefix(server, "https://")
+	case strings.HasPrefix(server, "http://"):
+		server = "ws://" + strings.TrimPrefix(server, "http://")
+	case !strings.HasPrefix(server, "ws://") && !strings.HasPrefix(server, "wss://"):
+		server = "wss://" + server
+	}
+	return server + path
+}
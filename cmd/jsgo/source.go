@@ -0,0 +1,221 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+)
+
+// getSource reads the package in dir and returns its import path, the imports of the package and the
+// source packages to send to the server: map[<package>]map[<filename>]<contents>. In a module all the
+// packages in the module are sent. In a GOPATH the package and any packages it imports from the same
+// GOPATH are sent. Other dependencies are downloaded by the server.
+func getSource(dir string) (string, []string, map[string]map[string]string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	source := map[string]map[string]string{}
+
+	if root, module, ok := findModule(dir); ok {
+		rel, err := filepath.Rel(root, dir)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		main := module
+		if rel != "." {
+			main = path.Join(module, filepath.ToSlash(rel))
+		}
+		if err := readModule(root, module, source); err != nil {
+			return "", nil, nil, err
+		}
+		if source[main] == nil {
+			return "", nil, nil, fmt.Errorf("no source files found in %s", dir)
+		}
+		return main, imports(source[main]), source, nil
+	}
+
+	for _, gopath := range filepath.SplitList(build.Default.GOPATH) {
+		src := filepath.Join(gopath, "src")
+		rel, err := filepath.Rel(src, dir)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		main := filepath.ToSlash(rel)
+		if err := readGopath(src, main, source); err != nil {
+			return "", nil, nil, err
+		}
+		if source[main] == nil {
+			return "", nil, nil, fmt.Errorf("no source files found in %s", dir)
+		}
+		return main, imports(source[main]), source, nil
+	}
+
+	return "", nil, nil, errors.New("directory must be in a module or a GOPATH")
+}
+
+// findModule searches dir and its parents for a go.mod file, and returns the module root and path.
+func findModule(dir string) (root, module string, ok bool) {
+	for {
+		if b, err := ioutil.ReadFile(filepath.Join(dir, "go.mod")); err == nil {
+			for _, line := range strings.Split(string(b), "\n") {
+				fields := strings.Fields(line)
+				if len(fields) == 2 && fields[0] == "module" {
+					return dir, strings.Trim(fields[1], `"`), true
+				}
+			}
+			return "", "", false
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", false
+		}
+		dir = parent
+	}
+}
+
+// readModule reads all the packages in the module at root. Packages in the vendor directory keep their
+// own import path.
+func readModule(root, module string, source map[string]map[string]string) error {
+	return filepath.Walk(root, func(fpath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if fpath == root {
+				return nil
+			}
+			if skipDir(info.Name()) {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(fpath, "go.mod")); err == nil {
+				// nested module
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(root, filepath.Dir(fpath))
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		var pkg string
+		switch {
+		case rel == ".":
+			pkg = module
+		case strings.HasPrefix(rel, "vendor/"):
+			pkg = strings.TrimPrefix(rel, "vendor/")
+		case rel == "vendor":
+			return nil
+		default:
+			pkg = module + "/" + rel
+		}
+		return readFile(source, pkg, fpath)
+	})
+}
+
+// readGopath reads the package at path in the GOPATH src dir, and recursively any packages it imports
+// from the same GOPATH.
+func readGopath(src, path string, source map[string]map[string]string) error {
+	if source[path] != nil {
+		return nil
+	}
+	dir := filepath.Join(src, filepath.FromSlash(path))
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		if info.IsDir() {
+			continue
+		}
+		if err := readFile(source, path, filepath.Join(dir, info.Name())); err != nil {
+			return err
+		}
+	}
+	if source[path] == nil {
+		return nil
+	}
+	for _, imp := range imports(source[path]) {
+		if _, err := os.Stat(filepath.Join(src, filepath.FromSlash(imp))); err != nil {
+			continue
+		}
+		if err := readGopath(src, imp, source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readFile(source map[string]map[string]string, pkg, fpath string) error {
+	if !validExtension(fpath) {
+		return nil
+	}
+	b, err := ioutil.ReadFile(fpath)
+	if err != nil {
+		return err
+	}
+	if source[pkg] == nil {
+		source[pkg] = map[string]string{}
+	}
+	source[pkg][filepath.Base(fpath)] = string(b)
+	return nil
+}
+
+// imports returns the imports of the non-test Go files in a source package.
+func imports(files map[string]string) []string {
+	found := map[string]bool{}
+	fset := token.NewFileSet()
+	for name, contents := range files {
+		if !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, name, contents, parser.ImportsOnly)
+		if err != nil {
+			continue
+		}
+		for _, spec := range f.Imports {
+			p, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				continue
+			}
+			found[p] = true
+		}
+	}
+	var paths []string
+	for p := range found {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func skipDir(name string) bool {
+	return name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")
+}
+
+func validExtension(name string) bool {
+	for _, ext := range config.ValidExtensions {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
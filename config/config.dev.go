@@ -10,13 +10,18 @@ package config
 const (
 	DEV = true
 
-	ErrorKind      = "ErrorDev"
-	CompileKind    = "CompileDev"
-	PackageKind    = "PackageDev"
-	DeployKind     = "DeployDev"
-	ShareKind      = "ShareDev"
-	HintsKind      = "HintsDev"
-	WasmDeployKind = "WasmDeployDev"
+	ErrorKind       = "ErrorDev"
+	CompileKind     = "CompileDev"
+	PackageKind     = "PackageDev"
+	DeployKind      = "DeployDev"
+	ShareKind       = "ShareDev"
+	HintsKind       = "HintsDev"
+	WasmDeployKind  = "WasmDeployDev"
+	DeployIndexKind = "DeployIndexDev"
+	CredentialsKind = "CredentialsDev"
+	UploadKind      = "UploadDev"
+	ShareStateKind  = "ShareStateDev"
+	ShareOwnerKind  = "ShareOwnerDev"
 )
 
 var Bucket = map[string]string{
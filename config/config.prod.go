@@ -10,13 +10,18 @@ package config
 const (
 	DEV = false
 
-	ErrorKind      = "Error"
-	CompileKind    = "Compile"
-	PackageKind    = "Package"
-	DeployKind     = "Deploy"
-	ShareKind      = "Share"
-	HintsKind      = "Hints"
-	WasmDeployKind = "WasmDeploy"
+	ErrorKind       = "Error"
+	CompileKind     = "Compile"
+	PackageKind     = "Package"
+	DeployKind      = "Deploy"
+	ShareKind       = "Share"
+	HintsKind       = "Hints"
+	WasmDeployKind  = "WasmDeploy"
+	DeployIndexKind = "DeployIndex"
+	CredentialsKind = "Credentials"
+	UploadKind      = "Upload"
+	ShareStateKind  = "ShareState"
+	ShareOwnerKind  = "ShareOwner"
 )
 
 var Bucket = map[string]string{
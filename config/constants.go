@@ -46,6 +46,9 @@ const (
 	// PageTimeout is the timeout when generating the compile page
 	PageTimeout = time.Second * 5
 
+	// ExportTimeout is the timeout when creating a downloadable archive of a compiled app
+	ExportTimeout = time.Second * 60
+
 	// ServerShutdownTimeout is the timeout when doing a graceful server shutdown
 	ServerShutdownTimeout = time.Second * 5
 
@@ -66,8 +69,79 @@ const (
 	HttpTimeout = time.Second * 5
 
 	ConcurrentStorageUploads = 10
+
+	// MaxUploadSize is the maximum size of a source archive uploaded to the jsgo compile server, and of
+	// the files extracted from it.
+	MaxUploadSize = 20 * 1024 * 1024
+
+	// WebsocketReadLimit is the maximum size of a message read from a websocket. Uploaded archives are
+	// base64 encoded in the message, so this allows for MaxUploadSize after encoding.
+	WebsocketReadLimit = MaxUploadSize/3*4 + 1024*1024
+
+	// MaxShareHistory is the maximum number of shares returned when following the parents of a share
+	MaxShareHistory = 100
+
+	// MaxReports is the maximum number of abuse reports stored for a share. Further reports are counted.
+	MaxReports = 20
+
+	// MaxReportReason is the maximum length of the reason given in an abuse report
+	MaxReportReason = 1000
+
+	// MaxShareRequestSize is the maximum size of the body of a request to delete or report a share
+	MaxShareRequestSize = 16 * 1024
+
+	// CredentialsKeyEnv is the environment variable holding the hex encoded 32 byte key used to encrypt
+	// stored credentials for private repositories.
+	CredentialsKeyEnv = "JSGO_CREDENTIALS_KEY"
+
+	// ProxyEnv is the environment variable holding the url of a GOPROXY protocol proxy (e.g.
+	// https://proxy.golang.org or file:///path/to/proxy). If set, modules are fetched from the proxy
+	// instead of cloning git repos.
+	ProxyEnv = "JSGO_PROXY"
+
+	// AliasesEnv is the environment variable holding extra short path aliases, as a comma separated list
+	// of alias=host pairs (e.g. "git:=git.example.com").
+	AliasesEnv = "JSGO_ALIASES"
+
+	// VanityCacheTime is the time the result of a go-import meta tag lookup is cached.
+	VanityCacheTime = time.Hour
+
+	// VanityCacheSize is the maximum number of go-import meta tag lookups that are cached.
+	VanityCacheSize = 10000
+
+	// EditorSessions is the maximum number of sessions cached for completion and hover requests. Each
+	// session holds the downloaded dependencies of the source.
+	EditorSessions = 20
+
+	// EditorSessionTime is the time a session for completion and hover requests is cached after it was
+	// last used.
+	EditorSessionTime = time.Minute * 10
+
+	// EditorFileSetSize is the total size of the files parsed for a cached editor session, after which
+	// the imported packages are discarded and imported again. Files are parsed for every request, and
+	// a token.FileSet never removes them.
+	EditorFileSetSize = 64 * 1024 * 1024
+
+	// RebuildInterval is the minimum time between compiles in the rebuild job, so the rebuild doesn't
+	// starve normal requests.
+	RebuildInterval = time.Second * 10
+
+	// ShareExpiryInterval is the time between removing expired playground shares
+	ShareExpiryInterval = time.Hour
+
+	// AdminTokenEnv is the environment variable holding the token for the admin page. The admin page is
+	// disabled if it's not set.
+	AdminTokenEnv = "JSGO_ADMIN_TOKEN"
 )
 
+// Aliases maps short path prefixes to code hosts: jsgo.io/gl:foo/bar is the same as
+// jsgo.io/gitlab.com/foo/bar. Bare user/repo paths are expanded to github.com separately.
+var Aliases = map[string]string{
+	"gh:": "github.com",
+	"gl:": "gitlab.com",
+	"bb:": "bitbucket.org",
+}
+
 var ValidExtensions = []string{".go", ".jsgo.html", ".inc.js", ".md"}
 
 var Buckets = []string{Bucket[Src], Bucket[Pkg], Bucket[Index], Bucket[Git]}
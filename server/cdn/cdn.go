@@ -0,0 +1,132 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package cdn
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/dave/services"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/store"
+)
+
+// PackageName is the name of the compiled JS for a package in the pkg bucket. The prelude is stored
+// with the path "prelude", so it follows the same pattern.
+func PackageName(path, hash string) string {
+	return fmt.Sprintf("%s.%s.js", path, hash)
+}
+
+// SourceMapName is the name of the source map for a package in the pkg bucket. The map with the Go
+// source in sourcesContent is stored separately, so the option doesn't depend on which compile stored
+// the map first.
+func SourceMapName(path, hash string, sourcesContent bool) string {
+	if sourcesContent {
+		return fmt.Sprintf("%s.%s.src.js.map", path, hash)
+	}
+	return PackageName(path, hash) + ".map"
+}
+
+// ModuleName is the name of the ES module for a package in the pkg bucket. The hash is the hash of the
+// module, not the package JS.
+func ModuleName(path, hash string) string {
+	return fmt.Sprintf("%s.%s.mjs", path, hash)
+}
+
+// EntryModuleName is the name of the ES module entry point for a compile in the pkg bucket.
+func EntryModuleName(path, hash string) string {
+	return fmt.Sprintf("%s.module.%s.mjs", path, hash)
+}
+
+// LoaderName is the name of the loader JS in the pkg bucket. Loaders for deploys from the playground
+// are not stored with a path, so path should be empty.
+func LoaderName(path, hash string) string {
+	if path == "" {
+		return fmt.Sprintf("%s.js", hash)
+	}
+	return fmt.Sprintf("%s.%s.js", path, hash)
+}
+
+// PackageUrl is the full URL of the compiled JS for a package.
+func PackageUrl(path, hash string) string {
+	return fmt.Sprintf("%s://%s/%s", config.Protocol[config.Pkg], config.Host[config.Pkg], PackageName(path, hash))
+}
+
+// ModuleUrl is the full URL of the ES module for a package.
+func ModuleUrl(path, hash string) string {
+	return fmt.Sprintf("%s://%s/%s", config.Protocol[config.Pkg], config.Host[config.Pkg], ModuleName(path, hash))
+}
+
+// EntryModuleUrl is the full URL of the ES module entry point.
+func EntryModuleUrl(path, hash string) string {
+	return fmt.Sprintf("%s://%s/%s", config.Protocol[config.Pkg], config.Host[config.Pkg], EntryModuleName(path, hash))
+}
+
+// LoaderUrl is the full URL of the loader JS.
+func LoaderUrl(path, hash string) string {
+	return fmt.Sprintf("%s://%s/%s", config.Protocol[config.Pkg], config.Host[config.Pkg], LoaderName(path, hash))
+}
+
+// Read gets the contents of a file from the fileserver, and returns an error if it doesn't exist.
+func Read(ctx context.Context, fileserver services.Fileserver, bucket, name string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	found, err := fileserver.Read(ctx, bucket, name, buf)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("%s not found in %s", name, bucket)
+	}
+	return buf.Bytes(), nil
+}
+
+// GzipSize returns the size of the contents after gzip compression, which is roughly the size
+// transferred when the file is served with gzip encoding.
+func GzipSize(contents []byte) int {
+	buf := &bytes.Buffer{}
+	w, _ := gzip.NewWriterLevel(buf, gzip.BestCompression)
+	w.Write(contents)
+	w.Close()
+	return buf.Len()
+}
+
+// ReadPackages gets the compiled JS for packages from the pkg bucket. The results are returned in the
+// same order as packages.
+func ReadPackages(ctx context.Context, fileserver services.Fileserver, packages []store.CompilePackage) ([][]byte, error) {
+	var outer error
+	var m sync.Mutex
+	contents := make([][]byte, len(packages))
+	wg := &sync.WaitGroup{}
+	limit := make(chan struct{}, config.ConcurrentStorageUploads)
+	for i, p := range packages {
+		i, p := i, p
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			limit <- struct{}{}
+			defer func() { <-limit }()
+			b, err := Read(ctx, fileserver, config.Bucket[config.Pkg], PackageName(p.Path, p.Hash))
+			m.Lock()
+			defer m.Unlock()
+			if err != nil {
+				if outer == nil {
+					outer = err
+				}
+				return
+			}
+			contents[i] = b
+		}()
+	}
+	wg.Wait()
+	if outer != nil {
+		return nil, outer
+	}
+	return contents, nil
+}
@@ -0,0 +1,82 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package cdn
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/dave/services"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/store"
+)
+
+// ExportDir is the directory in the export archive that holds the files from the pkg bucket.
+const ExportDir = "pkg/"
+
+// Export writes a zip archive containing everything needed to host an app without pkg.jsgo.io: the
+// index page, the loader, the prelude and every package. URLs of files in the pkg bucket are
+// rewritten to be relative to the index page, including the keys of the integrity map in the loader. The service
+// worker is stored next to the page on jsgo.io, so it's not registered by the exported page.
+func Export(ctx context.Context, fileserver services.Fileserver, w io.Writer, index, loader string, packages []store.CompilePackage) error {
+
+	indexContents, err := Read(ctx, fileserver, config.Bucket[config.Index], index)
+	if err != nil {
+		return err
+	}
+	loaderContents, err := Read(ctx, fileserver, config.Bucket[config.Pkg], loader)
+	if err != nil {
+		return err
+	}
+	packageContents, err := ReadPackages(ctx, fileserver, packages)
+	if err != nil {
+		return err
+	}
+
+	z := zip.NewWriter(w)
+
+	add := func(name string, contents []byte) error {
+		f, err := z.Create(name)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(contents); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	// Rewriting the URLs in the loader changes its digest.
+	loaderContents = relative(loaderContents)
+	indexContents = SetLoader(relative(RemoveServiceWorker(indexContents)), []string{ExportDir + loader}, ExportDir+loader, Integrity(loaderContents))
+
+	if err := add("index.html", indexContents); err != nil {
+		return err
+	}
+	if err := add(ExportDir+loader, loaderContents); err != nil {
+		return err
+	}
+	for i, p := range packages {
+		if err := add(ExportDir+PackageName(p.Path, p.Hash), packageContents[i]); err != nil {
+			return err
+		}
+	}
+
+	if err := z.Close(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// relative replaces absolute URLs of the pkg bucket with URLs relative to the index page.
+func relative(contents []byte) []byte {
+	host := []byte(fmt.Sprintf("%s://%s/", config.Protocol[config.Pkg], config.Host[config.Pkg]))
+	return bytes.Replace(contents, host, []byte(ExportDir), -1)
+}
@@ -0,0 +1,166 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package cdn
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/dave/services"
+	"github.com/dave/services/constor"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/store"
+)
+
+// Integrity returns the Subresource Integrity (sha384) digest of contents.
+func Integrity(contents []byte) string {
+	sum := sha512.Sum384(contents)
+	return "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// Hash returns the hash that names a file by its contents in the pkg and index buckets.
+func Hash(contents []byte) string {
+	return fmt.Sprintf("%x", sha1.Sum(contents))
+}
+
+// SecureLoader returns the loader with a script in front that sets the integrity of each package script
+// the loader adds to the document. The checks are part of the loader, so they apply wherever it's used
+// (e.g. the loader JS from the compile page), not just on index pages. packages is a map of URL ->
+// digest.
+func SecureLoader(loader []byte, packages map[string]string) ([]byte, error) {
+	b, err := json.Marshal(packages)
+	if err != nil {
+		return nil, err
+	}
+	script := fmt.Sprintf(integrityScript, b)
+	// Keep the "use strict" directive at the start of the loader, or it's no longer a directive.
+	if directive := []byte(`"use strict";`); bytes.HasPrefix(loader, directive) {
+		return append(append(append([]byte(nil), directive...), "\n"+script...), loader[len(directive):]...), nil
+	}
+	return append([]byte(script), loader...), nil
+}
+
+const integrityScript = `(function() {
+	var integrity = %s;
+	var append = Node.prototype.appendChild;
+	Node.prototype.appendChild = function(node) {
+		// The src attribute is used rather than the resolved URL, so the lookup still works when the URLs
+		// are rewritten to be relative (see Export).
+		var src = node.tagName === "SCRIPT" && node.getAttribute("src");
+		if (src && integrity[src]) {
+			node.integrity = integrity[src];
+			node.crossOrigin = "anonymous";
+		}
+		return append.call(this, node);
+	};
+})();
+`
+
+// SetLoader points the script tag in an index page that loads any of the urls at url, with integrity
+// and crossorigin attributes. Attributes added by an earlier call are replaced, so a page can be
+// rewritten more than once.
+func SetLoader(index []byte, urls []string, url, integrity string) []byte {
+	var quoted []string
+	for _, u := range urls {
+		quoted = append(quoted, regexp.QuoteMeta(u))
+	}
+	tag := regexp.MustCompile(`<script([^>]*?) src="(?:` + strings.Join(quoted, "|") + `)"([^>]*)>`)
+	return tag.ReplaceAllFunc(index, func(match []byte) []byte {
+		groups := tag.FindSubmatch(match)
+		before := loaderAttributes.ReplaceAll(groups[1], nil)
+		after := loaderAttributes.ReplaceAll(groups[2], nil)
+		return []byte(fmt.Sprintf(`<script%s src="%s" integrity="%s" crossorigin="anonymous"%s>`, before, html.EscapeString(url), integrity, after))
+	})
+}
+
+var loaderAttributes = regexp.MustCompile(`\s+(?:integrity|crossorigin)="[^"]*"`)
+
+// SetHead puts html in a marked block at the start of the head element of an index page. The block from
+// an earlier call is replaced, so a page can be rewritten more than once.
+func SetHead(index []byte, html string) []byte {
+	index = headBlock.ReplaceAll(index, nil)
+	// Earlier versions added scripts without the markers.
+	index = integrityTag.ReplaceAll(index, nil)
+	index = preloadTag.ReplaceAll(index, nil)
+	index = RemoveServiceWorker(index)
+	return InjectHead(index, headStart+html+headEnd)
+}
+
+const (
+	headStart = "<!-- jsgo -->\n"
+	headEnd   = "<!-- /jsgo -->\n"
+)
+
+var (
+	headBlock    = regexp.MustCompile(`(?s)` + regexp.QuoteMeta(headStart) + `.*?` + regexp.QuoteMeta(headEnd))
+	integrityTag = regexp.MustCompile(`(?s)<script>\s*\(function\(\) \{\s*var integrity = .*?</script>\n?`)
+	preloadTag   = regexp.MustCompile(`<link rel="preload" as="script" [^>]*>\n?`)
+)
+
+// SecureDeploy makes the files of a deploy with a hash named index page (e.g. from the playground) check
+// the packages with Subresource Integrity. It stores the loader from SecureLoader and an index page that
+// loads it with its digest, both named by the hash of their contents, and updates the hashes and digests
+// in contents. Loaders for deploys from the playground are not stored with a path, so path should be
+// empty.
+func SecureDeploy(ctx context.Context, fileserver services.Fileserver, send func(services.Message), path string, contents *store.DeployContents) error {
+
+	loader, err := Read(ctx, fileserver, config.Bucket[config.Pkg], LoaderName(path, contents.Main))
+	if err != nil {
+		return err
+	}
+	index, err := Read(ctx, fileserver, config.Bucket[config.Index], contents.Index)
+	if err != nil {
+		return err
+	}
+	packages, err := ReadPackages(ctx, fileserver, contents.Packages)
+	if err != nil {
+		return err
+	}
+
+	digests := map[string]string{}
+	for i, p := range contents.Packages {
+		contents.Packages[i].Integrity = Integrity(packages[i])
+		digests[PackageUrl(p.Path, p.Hash)] = contents.Packages[i].Integrity
+	}
+	loader, err = SecureLoader(loader, digests)
+	if err != nil {
+		return err
+	}
+	deployed := contents.Main
+	contents.Main = Hash(loader)
+	contents.Integrity = Integrity(loader)
+	index = SetLoader(index, []string{LoaderUrl(path, deployed), LoaderUrl(path, contents.Main)}, LoaderUrl(path, contents.Main), contents.Integrity)
+	contents.Index = Hash(index)
+
+	storer := constor.New(ctx, fileserver, send, config.ConcurrentStorageUploads)
+	defer storer.Close()
+	storer.Add(constor.Item{
+		Message:   "loader",
+		Name:      LoaderName(path, contents.Main),
+		Contents:  loader,
+		Bucket:    config.Bucket[config.Pkg],
+		Mime:      constor.MimeJs,
+		Immutable: true,
+	})
+	storer.Add(constor.Item{
+		Message:   "index",
+		Name:      contents.Index,
+		Contents:  index,
+		Bucket:    config.Bucket[config.Index],
+		Mime:      constor.MimeHtml,
+		Immutable: true,
+	})
+	return storer.Wait()
+}
@@ -0,0 +1,92 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package cdn
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/store"
+)
+
+// Manifest lists the files needed to run a compiled package: the loader, then the prelude and packages
+// in dependency order (the order the loader executes them). If the ES modules were created, Module is
+// the entry point and each package has the URL of its module.
+type Manifest struct {
+	Loader   ManifestFile
+	Module   string `json:",omitempty"`
+	Packages []ManifestFile
+}
+
+type ManifestFile struct {
+	Path      string `json:",omitempty"`
+	Url       string
+	Integrity string `json:",omitempty"`
+	Module    string `json:",omitempty"`
+}
+
+// NewManifest creates the manifest for a compile. Loaders for deploys from the playground are not stored
+// with a path, so path should be empty. module is the hash of the ES module entry point, or empty if
+// the modules weren't created.
+func NewManifest(path, main, integrity, module string, packages []store.CompilePackage) Manifest {
+	m := Manifest{
+		Loader: ManifestFile{Url: LoaderUrl(path, main), Integrity: integrity},
+	}
+	if module != "" {
+		m.Module = EntryModuleUrl(path, module)
+	}
+	for _, p := range packages {
+		f := ManifestFile{Path: p.Path, Url: PackageUrl(p.Path, p.Hash), Integrity: p.Integrity}
+		if p.Module != "" {
+			f.Module = ModuleUrl(p.Path, p.Module)
+		}
+		m.Packages = append(m.Packages, f)
+	}
+	return m
+}
+
+// Links returns link tags that preload the prelude and packages, so the browser can start downloading
+// them before the loader has run.
+func (m Manifest) Links() string {
+	buf := &bytes.Buffer{}
+	for _, f := range m.Packages {
+		fmt.Fprintf(buf, `<link rel="preload" as="script" href="%s" crossorigin="anonymous"`, html.EscapeString(f.Url))
+		if f.Integrity != "" {
+			fmt.Fprintf(buf, ` integrity="%s"`, f.Integrity)
+		}
+		buf.WriteString(">\n")
+	}
+	return buf.String()
+}
+
+// LinkHeader returns the value of an HTTP Link header that preloads the same files as Links.
+func (m Manifest) LinkHeader() string {
+	var links []string
+	for _, f := range m.Packages {
+		links = append(links, fmt.Sprintf("<%s>; rel=preload; as=script; crossorigin=anonymous", f.Url))
+	}
+	return strings.Join(links, ", ")
+}
+
+// ModuleLinkHeader returns the value of an HTTP Link header that preloads the ES modules of the prelude
+// and packages, then the entry point, for pages that import the entry point instead of running the
+// loader.
+func (m Manifest) ModuleLinkHeader() string {
+	if m.Module == "" {
+		return ""
+	}
+	var links []string
+	for _, f := range m.Packages {
+		if f.Module != "" {
+			links = append(links, fmt.Sprintf("<%s>; rel=modulepreload; crossorigin=anonymous", f.Module))
+		}
+	}
+	links = append(links, fmt.Sprintf("<%s>; rel=modulepreload; crossorigin=anonymous", m.Module))
+	return strings.Join(links, ", ")
+}
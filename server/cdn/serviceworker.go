@@ -0,0 +1,33 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package cdn
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// RegisterServiceWorker returns the script that registers the service worker at url from an index page,
+// with the page as the scope. The scope of the longest match controls a page, so other pages in the same
+// directory (e.g. the min and max pages of a package) keep their own workers. The scope is taken from the
+// location, so it doesn't depend on the name the page is stored with.
+func RegisterServiceWorker(url string) string {
+	return fmt.Sprintf(registerServiceWorker, url)
+}
+
+// RemoveServiceWorker removes the script added by RegisterServiceWorker from an index page.
+func RemoveServiceWorker(index []byte) []byte {
+	return registerServiceWorkerTag.ReplaceAll(index, nil)
+}
+
+const registerServiceWorker = `<script>
+if ("serviceWorker" in navigator) {
+	navigator.serviceWorker.register(%q, {scope: location.pathname});
+}
+</script>
+`
+
+var registerServiceWorkerTag = regexp.MustCompile(`(?s)<script>\s*if \("serviceWorker" in navigator\) \{.*?</script>\n?`)
@@ -0,0 +1,340 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+// Package checker type checks source packages and runs vet-style analysis passes over them, returning
+// the problems as diagnostics that can be shown in the editor.
+package checker
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/dave/services/srcimporter"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/assign"
+	"golang.org/x/tools/go/analysis/passes/atomic"
+	"golang.org/x/tools/go/analysis/passes/bools"
+	"golang.org/x/tools/go/analysis/passes/composite"
+	"golang.org/x/tools/go/analysis/passes/copylock"
+	"golang.org/x/tools/go/analysis/passes/httpresponse"
+	"golang.org/x/tools/go/analysis/passes/loopclosure"
+	"golang.org/x/tools/go/analysis/passes/lostcancel"
+	"golang.org/x/tools/go/analysis/passes/nilfunc"
+	"golang.org/x/tools/go/analysis/passes/printf"
+	"golang.org/x/tools/go/analysis/passes/shift"
+	"golang.org/x/tools/go/analysis/passes/stdmethods"
+	"golang.org/x/tools/go/analysis/passes/structtag"
+	"golang.org/x/tools/go/analysis/passes/unmarshal"
+	"golang.org/x/tools/go/analysis/passes/unreachable"
+	"golang.org/x/tools/go/analysis/passes/unusedresult"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/servermsg"
+)
+
+// Analyzers are the analysis passes that are run after type checking. These are a subset of go vet.
+var Analyzers = []*analysis.Analyzer{
+	assign.Analyzer,
+	atomic.Analyzer,
+	bools.Analyzer,
+	composite.Analyzer,
+	copylock.Analyzer,
+	httpresponse.Analyzer,
+	loopclosure.Analyzer,
+	lostcancel.Analyzer,
+	nilfunc.Analyzer,
+	printf.Analyzer,
+	shift.Analyzer,
+	stdmethods.Analyzer,
+	structtag.Analyzer,
+	unmarshal.Analyzer,
+	unreachable.Analyzer,
+	unusedresult.Analyzer,
+}
+
+// sizes are the sizes of the GopherJS target, where int and uintptr are 32 bits, so the shift and
+// atomic passes check the code as it will run.
+var sizes = types.SizesFor("gc", "386")
+
+// Check type checks every package in source, and runs the analysis passes over packages without errors.
+// Dependencies are imported from the build context with srcimporter, so they must already have been
+// downloaded. Test files are not checked.
+func Check(bctx *build.Context, source map[string]map[string]string) ([]servermsg.Diagnostic, error) {
+	fset := token.NewFileSet()
+	packages := map[string]*types.Package{}
+	importer := srcimporter.New(bctx, fset, packages)
+
+	var paths []string
+	for path := range source {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var diagnostics []servermsg.Diagnostic
+	for _, path := range paths {
+		d, err := checkPackage(bctx, fset, importer, path, source[path])
+		if err != nil {
+			return nil, err
+		}
+		diagnostics = append(diagnostics, d...)
+	}
+	return diagnostics, nil
+}
+
+func checkPackage(bctx *build.Context, fset *token.FileSet, importer types.Importer, path string, files map[string]string) ([]servermsg.Diagnostic, error) {
+	dir := filepath.Join(bctx.GOPATH, "src", path)
+
+	// Files must be in the same order each time
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var diagnostics []servermsg.Diagnostic
+	var parsed []*ast.File
+	for _, name := range names {
+		if !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		match, err := bctx.MatchFile(dir, name)
+		if err != nil {
+			return nil, err
+		}
+		if !match {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), files[name], parser.ParseComments)
+		if err != nil {
+			list, ok := err.(scanner.ErrorList)
+			if !ok {
+				return nil, err
+			}
+			for _, e := range list {
+				diagnostics = append(diagnostics, servermsg.Diagnostic{
+					Path:    path,
+					Pos:     position(e.Pos),
+					Source:  "syntax",
+					Message: e.Msg,
+				})
+			}
+			continue
+		}
+		parsed = append(parsed, f)
+	}
+	if len(diagnostics) > 0 {
+		// Type checking code with syntax errors gives confusing errors.
+		return diagnostics, nil
+	}
+	if len(parsed) == 0 {
+		return nil, nil
+	}
+
+	tc := types.Config{
+		Importer: importer,
+		Sizes:    sizes,
+		Error: func(err error) {
+			e, ok := err.(types.Error)
+			if !ok {
+				diagnostics = append(diagnostics, servermsg.Diagnostic{Path: path, Source: "types", Message: err.Error()})
+				return
+			}
+			diagnostics = append(diagnostics, servermsg.Diagnostic{
+				Path:    path,
+				Pos:     position(e.Fset.Position(e.Pos)),
+				Source:  "types",
+				Message: e.Msg,
+			})
+		},
+	}
+	info := &types.Info{
+		Types:      map[ast.Expr]types.TypeAndValue{},
+		Defs:       map[*ast.Ident]types.Object{},
+		Uses:       map[*ast.Ident]types.Object{},
+		Implicits:  map[ast.Node]types.Object{},
+		Selections: map[*ast.SelectorExpr]*types.Selection{},
+		Scopes:     map[ast.Node]*types.Scope{},
+	}
+	pkg, _ := tc.Check(path, fset, parsed, info)
+	if len(diagnostics) > 0 {
+		// The analysis passes assume the package is well typed, like go vet.
+		return diagnostics, nil
+	}
+
+	a := &analyzer{
+		fset:    fset,
+		files:   parsed,
+		pkg:     pkg,
+		info:    info,
+		sizes:   sizes,
+		results: map[*analysis.Analyzer]interface{}{},
+		facts:   map[factKey]analysis.Fact{},
+	}
+	for _, analyzer := range Analyzers {
+		if err := a.run(analyzer); err != nil {
+			return nil, err
+		}
+	}
+	for _, d := range a.diagnostics {
+		diagnostics = append(diagnostics, a.convert(path, d))
+	}
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		if diagnostics[i].Pos.File != diagnostics[j].Pos.File {
+			return diagnostics[i].Pos.File < diagnostics[j].Pos.File
+		}
+		return diagnostics[i].Pos.Offset < diagnostics[j].Pos.Offset
+	})
+	return diagnostics, nil
+}
+
+// analyzer runs analysis passes over a single package. Facts from dependencies aren't available, so
+// passes that use facts only see facts exported by this package.
+type analyzer struct {
+	fset        *token.FileSet
+	files       []*ast.File
+	pkg         *types.Package
+	info        *types.Info
+	sizes       types.Sizes
+	results     map[*analysis.Analyzer]interface{}
+	facts       map[factKey]analysis.Fact
+	diagnostics []analysisDiagnostic
+}
+
+type analysisDiagnostic struct {
+	analysis.Diagnostic
+	analyzer *analysis.Analyzer
+}
+
+type factKey struct {
+	obj types.Object   // nil for package facts
+	pkg *types.Package // nil for object facts
+	typ reflect.Type
+}
+
+// run runs the analysis pass after the passes it requires. Each pass is only run once.
+func (a *analyzer) run(analyzer *analysis.Analyzer) error {
+	if _, done := a.results[analyzer]; done {
+		return nil
+	}
+	resultOf := map[*analysis.Analyzer]interface{}{}
+	for _, req := range analyzer.Requires {
+		if err := a.run(req); err != nil {
+			return err
+		}
+		resultOf[req] = a.results[req]
+	}
+	pass := &analysis.Pass{
+		Analyzer:   analyzer,
+		Fset:       a.fset,
+		Files:      a.files,
+		Pkg:        a.pkg,
+		TypesInfo:  a.info,
+		TypesSizes: a.sizes,
+		ResultOf:   resultOf,
+		Report: func(d analysis.Diagnostic) {
+			a.diagnostics = append(a.diagnostics, analysisDiagnostic{d, analyzer})
+		},
+		ImportObjectFact: func(obj types.Object, fact analysis.Fact) bool {
+			return a.importFact(factKey{obj: obj, typ: reflect.TypeOf(fact)}, fact)
+		},
+		ExportObjectFact: func(obj types.Object, fact analysis.Fact) {
+			a.facts[factKey{obj: obj, typ: reflect.TypeOf(fact)}] = fact
+		},
+		ImportPackageFact: func(pkg *types.Package, fact analysis.Fact) bool {
+			return a.importFact(factKey{pkg: pkg, typ: reflect.TypeOf(fact)}, fact)
+		},
+		ExportPackageFact: func(fact analysis.Fact) {
+			a.facts[factKey{pkg: a.pkg, typ: reflect.TypeOf(fact)}] = fact
+		},
+		AllObjectFacts: func() []analysis.ObjectFact {
+			var facts []analysis.ObjectFact
+			for k, f := range a.facts {
+				if k.obj != nil && isFactType(analyzer, k.typ) {
+					facts = append(facts, analysis.ObjectFact{Object: k.obj, Fact: f})
+				}
+			}
+			return facts
+		},
+		AllPackageFacts: func() []analysis.PackageFact {
+			var facts []analysis.PackageFact
+			for k, f := range a.facts {
+				if k.pkg != nil && isFactType(analyzer, k.typ) {
+					facts = append(facts, analysis.PackageFact{Package: k.pkg, Fact: f})
+				}
+			}
+			return facts
+		},
+	}
+	result, err := analyzer.Run(pass)
+	if err != nil {
+		return fmt.Errorf("%s: %v", analyzer.Name, err)
+	}
+	a.results[analyzer] = result
+	return nil
+}
+
+func (a *analyzer) importFact(key factKey, fact analysis.Fact) bool {
+	f, ok := a.facts[key]
+	if !ok {
+		return false
+	}
+	reflect.ValueOf(fact).Elem().Set(reflect.ValueOf(f).Elem())
+	return true
+}
+
+func isFactType(analyzer *analysis.Analyzer, typ reflect.Type) bool {
+	for _, f := range analyzer.FactTypes {
+		if reflect.TypeOf(f) == typ {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *analyzer) convert(path string, d analysisDiagnostic) servermsg.Diagnostic {
+	out := servermsg.Diagnostic{
+		Path:    path,
+		Pos:     a.position(d.Pos),
+		End:     a.position(d.End),
+		Source:  d.analyzer.Name,
+		Message: d.Message,
+	}
+	for _, fix := range d.SuggestedFixes {
+		f := servermsg.SuggestedFix{Message: fix.Message}
+		for _, edit := range fix.TextEdits {
+			f.Edits = append(f.Edits, servermsg.TextEdit{
+				Pos:     a.position(edit.Pos),
+				End:     a.position(edit.End),
+				NewText: string(edit.NewText),
+			})
+		}
+		out.Fixes = append(out.Fixes, f)
+	}
+	return out
+}
+
+func (a *analyzer) position(pos token.Pos) servermsg.Position {
+	if !pos.IsValid() {
+		return servermsg.Position{}
+	}
+	return position(a.fset.Position(pos))
+}
+
+func position(p token.Position) servermsg.Position {
+	return servermsg.Position{
+		File:   filepath.Base(p.Filename),
+		Offset: p.Offset,
+		Line:   p.Line,
+		Column: p.Column,
+	}
+}
@@ -0,0 +1,131 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package checker
+
+import (
+	"fmt"
+	"go/build"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/servermsg"
+)
+
+func TestCheck(t *testing.T) {
+	type diagnostic struct {
+		source  string
+		pos     string // file:line:column
+		message string // Must be in the message
+		edits   []string
+	}
+	type spec struct {
+		source   map[string]map[string]string
+		expected []diagnostic
+	}
+	tests := map[string]spec{
+		"no problems": {
+			source: map[string]map[string]string{
+				"a": {"a.go": "package a\n\nimport \"fmt\"\n\nfunc F() { fmt.Println(1) }\n"},
+			},
+		},
+		"syntax": {
+			source: map[string]map[string]string{
+				"a": {
+					"a.go": "package a\n\nfunc F() {\n\tx :=\n}\n",
+					"b.go": "package a\n\nvar y int = \"not checked\"\n",
+				},
+			},
+			expected: []diagnostic{
+				{source: "syntax", pos: "a.go:5:1", message: "expected operand"},
+			},
+		},
+		"types": {
+			source: map[string]map[string]string{
+				"a": {"a.go": "package a\n\nfunc F() int {\n\treturn x\n}\n"},
+			},
+			expected: []diagnostic{
+				{source: "types", pos: "a.go:4:9", message: "undefined: x"},
+			},
+		},
+		"vet": {
+			source: map[string]map[string]string{
+				"a": {"a.go": "package a\n\nimport \"fmt\"\n\nfunc F(s string) {\n\tfmt.Printf(\"%d\\n\", s)\n\treturn\n\tfmt.Println(s)\n}\n"},
+			},
+			expected: []diagnostic{
+				{source: "printf", pos: "a.go:6:2", message: "format %d has arg s of wrong type string"},
+				{source: "unreachable", pos: "a.go:8:2", message: "unreachable code", edits: []string{`a.go:8:2-a.go:8:16 ""`}},
+			},
+		},
+		"test files not checked": {
+			source: map[string]map[string]string{
+				"a": {
+					"a.go":      "package a\n",
+					"a_test.go": "package a\n\nvar y int = \"not checked\"\n",
+				},
+			},
+		},
+		"each package": {
+			source: map[string]map[string]string{
+				"a": {"a.go": "package a\n\nvar A int = \"a\"\n"},
+				"b": {"b.go": "package b\n\nvar B int = \"b\"\n"},
+			},
+			expected: []diagnostic{
+				{source: "types", pos: "a.go:3:13", message: "cannot use \"a\""},
+				{source: "types", pos: "b.go:3:13", message: "cannot use \"b\""},
+			},
+		},
+	}
+	for name, test := range tests {
+		// The build context reads the source like the session's build context, and the standard library
+		// from the host.
+		source := test.source
+		bctx := build.Default
+		bctx.GOPATH = "/gopath"
+		bctx.OpenFile = func(path string) (io.ReadCloser, error) {
+			dir, name := filepath.Split(path)
+			if files, ok := source[strings.TrimPrefix(filepath.Clean(dir), "/gopath/src/")]; ok {
+				if contents, ok := files[name]; ok {
+					return ioutil.NopCloser(strings.NewReader(contents)), nil
+				}
+			}
+			return os.Open(path)
+		}
+		diagnostics, err := Check(&bctx, test.source)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		var found []diagnostic
+		for _, d := range diagnostics {
+			f := diagnostic{source: d.Source, pos: positionString(d.Pos), message: d.Message}
+			for _, fix := range d.Fixes {
+				for _, e := range fix.Edits {
+					f.edits = append(f.edits, fmt.Sprintf("%s-%s %q", positionString(e.Pos), positionString(e.End), e.NewText))
+				}
+			}
+			found = append(found, f)
+		}
+		if len(found) != len(test.expected) {
+			t.Errorf("%s: expected %d diagnostics, got %d: %v", name, len(test.expected), len(found), found)
+			continue
+		}
+		for i, expected := range test.expected {
+			f := found[i]
+			if f.source != expected.source || f.pos != expected.pos || !strings.Contains(f.message, expected.message) || !reflect.DeepEqual(f.edits, expected.edits) {
+				t.Errorf("%s: expected %v, got %v", name, expected, f)
+			}
+		}
+	}
+}
+
+func positionString(p servermsg.Position) string {
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
@@ -0,0 +1,248 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package checker
+
+import (
+	"go/ast"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/frizz/gotypes"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/frizz/gotypes/convert"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/servermsg"
+)
+
+// Complete returns the identifiers that complete the code at offset in file. After a selector (e.g.
+// "fmt.Pr" or "x.") the candidates are the members of the package or type, otherwise they are the
+// identifiers in scope.
+func (ld *Loader) Complete(source map[string]map[string]string, path, file string, offset int) ([]servermsg.Candidate, error) {
+	l, err := ld.load(source, path, file, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	var prefix string
+	var objects []types.Object
+	// The cursor is usually at the end of the identifier being completed, so find the node that contains
+	// the character before the cursor.
+	start := l.pos
+	if start > l.file.Pos() {
+		start--
+	}
+	nodes, _ := astutil.PathEnclosingInterval(l.file, start, l.pos)
+	if sel := l.selector(nodes); sel != nil {
+		prefix = l.identPrefix(sel.Sel)
+		objects = l.members(sel.X)
+	} else {
+		if len(nodes) > 0 {
+			if id, ok := nodes[0].(*ast.Ident); ok {
+				prefix = l.identPrefix(id)
+			}
+		}
+		objects = l.scope()
+	}
+
+	done := map[string]bool{}
+	var candidates []servermsg.Candidate
+	for _, obj := range objects {
+		name := obj.Name()
+		if done[name] || name == "_" || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if obj.Pkg() != nil && obj.Pkg() != l.pkg && !obj.Exported() {
+			continue
+		}
+		done[name] = true
+		candidates = append(candidates, servermsg.Candidate{
+			Name: name,
+			Kind: objectKind(obj),
+			Type: l.typeString(obj),
+		})
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Name < candidates[j].Name })
+	return candidates, nil
+}
+
+// selector returns the selector expression if the cursor is after the dot.
+func (l *loaded) selector(nodes []ast.Node) *ast.SelectorExpr {
+	for _, n := range nodes {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			continue
+		}
+		if l.pos > sel.X.End() {
+			return sel
+		}
+		return nil
+	}
+	return nil
+}
+
+// identPrefix returns the part of the identifier before the cursor. The parser uses "_" for a missing
+// selector after a dot.
+func (l *loaded) identPrefix(id *ast.Ident) string {
+	if id.Name == "_" || l.pos < id.Pos() {
+		return ""
+	}
+	n := int(l.pos - id.Pos())
+	if n > len(id.Name) {
+		n = len(id.Name)
+	}
+	return id.Name[:n]
+}
+
+// members returns the exported members of an imported package, or the fields and methods of the type of
+// the expression.
+func (l *loaded) members(x ast.Expr) []types.Object {
+	if id, ok := x.(*ast.Ident); ok {
+		if pn, ok := l.info.Uses[id].(*types.PkgName); ok {
+			var objects []types.Object
+			scope := pn.Imported().Scope()
+			for _, name := range scope.Names() {
+				objects = append(objects, scope.Lookup(name))
+			}
+			return objects
+		}
+	}
+	tv, ok := l.info.Types[x]
+	if !ok || tv.Type == nil {
+		return nil
+	}
+	typ := tv.Type
+	var objects []types.Object
+	if tv.IsType() {
+		// Method expressions, e.g. T.Method
+		typ = types.NewPointer(typ)
+	} else {
+		objects = append(objects, fields(typ, map[types.Type]bool{})...)
+	}
+	ptr := typ
+	if _, isPtr := typ.Underlying().(*types.Pointer); !isPtr {
+		if _, isInterface := typ.Underlying().(*types.Interface); !isInterface {
+			ptr = types.NewPointer(typ)
+		}
+	}
+	ms := types.NewMethodSet(ptr)
+	for i := 0; i < ms.Len(); i++ {
+		objects = append(objects, ms.At(i).Obj())
+	}
+	return objects
+}
+
+// fields returns the fields of a struct, including promoted fields of embedded structs.
+func fields(typ types.Type, visited map[types.Type]bool) []types.Object {
+	if p, ok := typ.Underlying().(*types.Pointer); ok {
+		typ = p.Elem()
+	}
+	if visited[typ] {
+		return nil
+	}
+	visited[typ] = true
+	s, ok := typ.Underlying().(*types.Struct)
+	if !ok {
+		return nil
+	}
+	var objects []types.Object
+	for i := 0; i < s.NumFields(); i++ {
+		objects = append(objects, s.Field(i))
+	}
+	for i := 0; i < s.NumFields(); i++ {
+		if s.Field(i).Anonymous() {
+			objects = append(objects, fields(s.Field(i).Type(), visited)...)
+		}
+	}
+	return objects
+}
+
+// scope returns the objects in scope at the cursor, innermost first.
+func (l *loaded) scope() []types.Object {
+	if l.pkg == nil {
+		return nil
+	}
+	var objects []types.Object
+	for scope := l.pkg.Scope().Innermost(l.pos); scope != nil; scope = scope.Parent() {
+		local := scope != l.pkg.Scope() && scope != types.Universe && scope.Parent() != l.pkg.Scope()
+		for _, name := range scope.Names() {
+			obj := scope.Lookup(name)
+			if local && obj.Pos() > l.pos {
+				// Local variables are only in scope after they're declared
+				continue
+			}
+			objects = append(objects, obj)
+		}
+	}
+	return objects
+}
+
+// objectKind returns the Candidate.Kind of the object.
+func objectKind(obj types.Object) string {
+	switch obj := obj.(type) {
+	case *types.Var:
+		if obj.IsField() {
+			return "field"
+		}
+		return "var"
+	case *types.Const:
+		return "const"
+	case *types.TypeName:
+		return "type"
+	case *types.Func:
+		if sig, ok := obj.Type().(*types.Signature); ok && sig.Recv() != nil {
+			return "method"
+		}
+		return "func"
+	case *types.PkgName:
+		return "package"
+	case *types.Builtin:
+		return "builtin"
+	case *types.Nil:
+		return "var"
+	}
+	return ""
+}
+
+// typeString formats the type of the object with gotypes.
+func (l *loaded) typeString(obj types.Object) string {
+	switch obj := obj.(type) {
+	case *types.PkgName:
+		return obj.Imported().Path()
+	case *types.Builtin, *types.Nil:
+		return ""
+	case *types.TypeName:
+		return l.gotypeString(obj.Type().Underlying())
+	}
+	return l.gotypeString(obj.Type())
+}
+
+func (l *loaded) gotypeString(typ types.Type) (s string) {
+	defer func() {
+		// convert panics for types it doesn't support
+		if r := recover(); r != nil {
+			s = types.TypeString(typ, func(p *types.Package) string { return l.qualifier(p.Path()) })
+		}
+	}()
+	return gotypes.TypeString(convert.Type(typ, false), l.qualifier)
+}
+
+// qualifier qualifies types in other packages with the package name, and types in this package are
+// unqualified.
+func (l *loaded) qualifier(path string) string {
+	if l.pkg == nil {
+		return path
+	}
+	if path == l.pkg.Path() {
+		return ""
+	}
+	for _, p := range l.pkg.Imports() {
+		if p.Path() == path {
+			return p.Name()
+		}
+	}
+	return path
+}
@@ -0,0 +1,150 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package checker
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/servermsg"
+)
+
+// Hover returns the signature and doc comment of the identifier at offset in file, or nil if there's no
+// identifier at the cursor.
+func (ld *Loader) Hover(source map[string]map[string]string, path, file string, offset int) (*servermsg.HoverInfo, error) {
+	l, err := ld.load(source, path, file, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, _ := astutil.PathEnclosingInterval(l.file, l.pos, l.pos)
+	if len(nodes) == 0 {
+		return nil, nil
+	}
+	id, ok := nodes[0].(*ast.Ident)
+	if !ok {
+		return nil, nil
+	}
+	obj := l.object(id)
+	if obj == nil {
+		return nil, nil
+	}
+
+	return &servermsg.HoverInfo{
+		Name:      obj.Name(),
+		Kind:      objectKind(obj),
+		Signature: l.signature(obj),
+		Doc:       l.doc(obj),
+		Pos:       position(l.fset.Position(id.Pos())),
+		End:       position(l.fset.Position(id.End())),
+	}, nil
+}
+
+// signature returns the declaration of the object, formatted with gotypes.
+func (l *loaded) signature(obj types.Object) string {
+	switch obj := obj.(type) {
+	case *types.Func:
+		sig, ok := obj.Type().(*types.Signature)
+		if !ok {
+			return "func " + obj.Name()
+		}
+		var recv string
+		if sig.Recv() != nil {
+			recv = fmt.Sprintf("(%s) ", l.gotypeString(sig.Recv().Type()))
+		}
+		return "func " + recv + obj.Name() + strings.TrimPrefix(l.gotypeString(sig), "func")
+	case *types.Var:
+		if obj.IsField() {
+			return "field " + obj.Name() + " " + l.gotypeString(obj.Type())
+		}
+		return "var " + obj.Name() + " " + l.gotypeString(obj.Type())
+	case *types.Const:
+		return fmt.Sprintf("const %s %s = %s", obj.Name(), l.gotypeString(obj.Type()), obj.Val().ExactString())
+	case *types.TypeName:
+		return "type " + obj.Name() + " " + l.gotypeString(obj.Type().Underlying())
+	case *types.PkgName:
+		return fmt.Sprintf("package %s (%q)", obj.Name(), obj.Imported().Path())
+	case *types.Builtin:
+		return "builtin " + obj.Name()
+	case *types.Label:
+		return "label " + obj.Name()
+	}
+	return obj.Name()
+}
+
+// doc returns the doc comment of the object. Objects in dependencies are found by parsing the file they
+// were declared in.
+func (l *loaded) doc(obj types.Object) string {
+	if !obj.Pos().IsValid() {
+		return ""
+	}
+	p := l.fset.Position(obj.Pos())
+
+	fset := l.fset
+	var f *ast.File
+	for _, file := range l.files {
+		if l.fset.File(file.Pos()).Name() == p.Filename {
+			f = file
+			break
+		}
+	}
+	if f == nil {
+		b, err := l.readFile(p.Filename)
+		if err != nil {
+			return ""
+		}
+		fset = token.NewFileSet()
+		if f, err = parser.ParseFile(fset, p.Filename, b, parser.ParseComments); err != nil {
+			return ""
+		}
+	}
+
+	pos := fset.File(f.Pos()).Pos(p.Offset)
+	nodes, _ := astutil.PathEnclosingInterval(f, pos, pos)
+	for _, n := range nodes {
+		var doc *ast.CommentGroup
+		switch n := n.(type) {
+		case *ast.Field:
+			doc = n.Doc
+		case *ast.ValueSpec:
+			doc = n.Doc
+		case *ast.TypeSpec:
+			doc = n.Doc
+		case *ast.FuncDecl:
+			doc = n.Doc
+		case *ast.GenDecl:
+			doc = n.Doc
+		}
+		if doc != nil {
+			return doc.Text()
+		}
+	}
+	return ""
+}
+
+func (l *loaded) readFile(name string) ([]byte, error) {
+	var r io.ReadCloser
+	var err error
+	if l.bctx.OpenFile != nil {
+		r, err = l.bctx.OpenFile(name)
+	} else {
+		r, err = os.Open(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
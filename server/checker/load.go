@@ -0,0 +1,164 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package checker
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dave/services/srcimporter"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+)
+
+// loaded is a package that has been type checked for completion or hover info. The code is usually
+// being edited, so syntax and type errors are ignored.
+type loaded struct {
+	bctx  *build.Context
+	fset  *token.FileSet
+	file  *ast.File // File containing the cursor
+	pos   token.Pos // Position of the cursor
+	pkg   *types.Package
+	info  *types.Info
+	files []*ast.File
+}
+
+// Loader type checks source packages for completion and hover info. Dependencies are imported from the
+// build context once and reused by later calls, so the loader must only be used by one goroutine at a
+// time.
+type Loader struct {
+	bctx     *build.Context
+	fset     *token.FileSet
+	packages map[string]*types.Package
+}
+
+func NewLoader(bctx *build.Context) *Loader {
+	return &Loader{
+		bctx:     bctx,
+		fset:     token.NewFileSet(),
+		packages: map[string]*types.Package{},
+	}
+}
+
+// forget removes the source packages from the imported packages, because they may have been edited
+// since they were imported. Packages that import a removed package are also removed.
+func (ld *Loader) forget(source map[string]map[string]string) {
+	for path := range source {
+		delete(ld.packages, path)
+	}
+	for changed := true; changed; {
+		changed = false
+		for path, pkg := range ld.packages {
+			for _, imp := range pkg.Imports() {
+				if imp != types.Unsafe && ld.packages[imp.Path()] != imp {
+					delete(ld.packages, path)
+					changed = true
+					break
+				}
+			}
+		}
+	}
+}
+
+// load type checks the package at path in the source, and finds the cursor at offset in file. Test
+// files are only included if the cursor is in a test file in the same package.
+func (ld *Loader) load(source map[string]map[string]string, path, file string, offset int) (*loaded, error) {
+	files, ok := source[path]
+	if !ok {
+		return nil, fmt.Errorf("package %s not found in source", path)
+	}
+	contents, ok := files[file]
+	if !ok {
+		return nil, fmt.Errorf("file %s not found in %s", file, path)
+	}
+	if offset < 0 || offset > len(contents) {
+		return nil, fmt.Errorf("offset %d out of range in %s", offset, file)
+	}
+
+	ld.forget(source)
+
+	// The files parsed for each call are kept by the file set, so it's replaced when it gets too large.
+	// The positions of the imported packages refer to the old file set, so they are imported again.
+	if ld.fset.Base() > config.EditorFileSetSize {
+		ld.fset = token.NewFileSet()
+		ld.packages = map[string]*types.Package{}
+	}
+
+	dir := filepath.Join(ld.bctx.GOPATH, "src", path)
+	l := &loaded{
+		bctx: ld.bctx,
+		fset: ld.fset,
+		info: &types.Info{
+			Types:      map[ast.Expr]types.TypeAndValue{},
+			Defs:       map[*ast.Ident]types.Object{},
+			Uses:       map[*ast.Ident]types.Object{},
+			Implicits:  map[ast.Node]types.Object{},
+			Selections: map[*ast.SelectorExpr]*types.Selection{},
+			Scopes:     map[ast.Node]*types.Scope{},
+		},
+	}
+
+	f, _ := parser.ParseFile(l.fset, filepath.Join(dir, file), contents, parser.ParseComments|parser.AllErrors)
+	if f == nil {
+		return nil, fmt.Errorf("can't parse %s", file)
+	}
+	l.file = f
+	l.pos = l.fset.File(f.Pos()).Pos(offset)
+	l.files = append(l.files, f)
+
+	// Files must be in the same order each time
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if name == file || !strings.HasSuffix(name, ".go") {
+			continue
+		}
+		if strings.HasSuffix(name, "_test.go") && !strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		match, err := ld.bctx.MatchFile(dir, name)
+		if err != nil {
+			return nil, err
+		}
+		if !match {
+			continue
+		}
+		f, _ := parser.ParseFile(l.fset, filepath.Join(dir, name), files[name], parser.ParseComments|parser.AllErrors)
+		if f == nil || f.Name.Name != l.file.Name.Name {
+			continue
+		}
+		l.files = append(l.files, f)
+	}
+
+	tc := types.Config{
+		Importer: srcimporter.New(ld.bctx, ld.fset, ld.packages),
+		Sizes:    sizes,
+		Error: func(err error) {
+			// Ignore errors here - the code is being edited.
+		},
+	}
+	l.pkg, _ = tc.Check(path, l.fset, l.files, l.info)
+	return l, nil
+}
+
+// object returns the object referred to or defined by the identifier.
+func (l *loaded) object(id *ast.Ident) types.Object {
+	if obj := l.info.Uses[id]; obj != nil {
+		return obj
+	}
+	return l.info.Defs[id]
+}
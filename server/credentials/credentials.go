@@ -0,0 +1,248 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package credentials
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"github.com/dave/services"
+	"github.com/dave/services/fetcher/gitfetcher"
+	"github.com/dave/services/fileserver/cachefileserver"
+	"github.com/dave/services/getter/cache"
+	"github.com/gorilla/websocket"
+	"gopkg.in/src-d/go-billy.v4"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/serverconfig"
+)
+
+// Data is stored in the database for each API token. The key is the sha256 hash of the token, so the
+// token itself is never stored.
+type Data struct {
+	Time        time.Time
+	Credentials []Credential
+}
+
+// Credential is an access token for private repositories with URLs that start with Prefix (e.g.
+// "github.com/my-org/"). Secret is encrypted with the key in the config.CredentialsKeyEnv
+// environment variable.
+type Credential struct {
+	Prefix   string
+	Username string
+	Secret   []byte `datastore:",noindex"`
+}
+
+// New returns a Credentials which creates git fetchers authenticated with the stored credentials.
+func New(database services.Database) *Credentials {
+	return &Credentials{database: database}
+}
+
+type Credentials struct {
+	database services.Database
+}
+
+// TokenProtocol prefixes the API token when it's sent as a websocket subprotocol.
+const TokenProtocol = "jsgo-token."
+
+// Token gets the API token from the request. It's sent in the "Authorization: Bearer <token>" header,
+// or by browsers, which can't set headers on websocket requests, as a TokenProtocol subprotocol. The
+// "token" query parameter is still accepted from older clients, but query strings end up in logs and
+// referrers so it shouldn't be used.
+func Token(req *http.Request) string {
+	if auth := req.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+	if protocol := TokenSubprotocol(req); protocol != "" {
+		return strings.TrimPrefix(protocol, TokenProtocol)
+	}
+	return req.URL.Query().Get("token")
+}
+
+// TokenSubprotocol returns the websocket subprotocol that carries the API token, or "" if there is none.
+// The server must select it in the handshake response or the browser closes the connection.
+func TokenSubprotocol(req *http.Request) string {
+	for _, protocol := range websocket.Subprotocols(req) {
+		if strings.HasPrefix(protocol, TokenProtocol) {
+			return protocol
+		}
+	}
+	return ""
+}
+
+// Register adds a credential to the API token. If token is empty, a new token is created. The token is
+// returned.
+func (c *Credentials) Register(ctx context.Context, token, prefix, username, secret string) (string, error) {
+	if prefix == "" || secret == "" {
+		return "", errors.New("prefix and secret must be specified")
+	}
+	var data Data
+	if token == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		token = hex.EncodeToString(b)
+	} else {
+		found, d, err := c.lookup(ctx, token)
+		if err != nil {
+			return "", err
+		}
+		if !found {
+			return "", errors.New("token not found")
+		}
+		data = d
+	}
+	encrypted, err := encrypt([]byte(secret))
+	if err != nil {
+		return "", err
+	}
+	data.Time = time.Now()
+	data.Credentials = append(data.Credentials, Credential{
+		Prefix:   strings.TrimSuffix(prefix, "/") + "/",
+		Username: username,
+		Secret:   encrypted,
+	})
+	if _, err := c.database.Put(ctx, key(token), &data); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Cache returns the cache to use for the request. If the request has an API token with stored
+// credentials, a new cache is returned which authenticates git requests that match the credentials.
+// In this case private is true: the request must not save getter hints, and cloned repos are not
+// persisted, so private repos can't leak to other users. In local mode the shared cache reads the local
+// GOPATH, so it's used for requests with a token too, but the request is still private.
+func (c *Credentials) Cache(ctx context.Context, req *http.Request, shared *cache.Cache) (result *cache.Cache, private bool, err error) {
+	token := Token(req)
+	if token == "" {
+		return shared, false, nil
+	}
+	found, data, err := c.lookup(ctx, token)
+	if err != nil {
+		return nil, false, err
+	}
+	if !found {
+		return nil, false, errors.New("token not found")
+	}
+	if config.LOCAL {
+		return shared, true, nil
+	}
+	f := &fetcher{
+		fetcher: gitfetcher.New(
+			cachefileserver.New(256*1024*1024, 100*1024*1024),
+			cachefileserver.New(256*1024*1024, 100*1024*1024),
+			serverconfig.GitFetcherConfig,
+		),
+	}
+	for _, credential := range data.Credentials {
+		secret, err := decrypt(credential.Secret)
+		if err != nil {
+			return nil, false, err
+		}
+		f.credentials = append(f.credentials, credential)
+		f.secrets = append(f.secrets, string(secret))
+	}
+	return cache.New(c.database, f, nil, config.HintsKind), true, nil
+}
+
+func (c *Credentials) lookup(ctx context.Context, token string) (bool, Data, error) {
+	var data Data
+	if err := c.database.Get(ctx, key(token), &data); err != nil {
+		if err == datastore.ErrNoSuchEntity {
+			return false, Data{}, nil
+		}
+		return false, Data{}, err
+	}
+	return true, data, nil
+}
+
+// fetcher adds the username and secret of the matching credential to the URL of each git request.
+type fetcher struct {
+	fetcher     services.Fetcher
+	credentials []Credential
+	secrets     []string
+}
+
+func (f *fetcher) Fetch(ctx context.Context, repo string) (billy.Filesystem, error) {
+	u, err := url.Parse(repo)
+	if err != nil {
+		return nil, err
+	}
+	hostPath := u.Host + u.Path + "/"
+	best := -1
+	for i, credential := range f.credentials {
+		if !strings.HasPrefix(hostPath, credential.Prefix) {
+			continue
+		}
+		if best == -1 || len(credential.Prefix) > len(f.credentials[best].Prefix) {
+			best = i
+		}
+	}
+	if best > -1 {
+		u.Scheme = "https"
+		u.User = url.UserPassword(f.credentials[best].Username, f.secrets[best])
+	}
+	return f.fetcher.Fetch(ctx, u.String())
+}
+
+func key(token string) *datastore.Key {
+	sum := sha256.Sum256([]byte(token))
+	return datastore.NameKey(config.CredentialsKind, hex.EncodeToString(sum[:]), nil)
+}
+
+func encrypt(plaintext []byte) ([]byte, error) {
+	gcm, err := getCipher()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func decrypt(ciphertext []byte) ([]byte, error) {
+	gcm, err := getCipher()
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("malformed credential")
+	}
+	nonce, ciphertext := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func getCipher() (cipher.AEAD, error) {
+	key, err := hex.DecodeString(os.Getenv(config.CredentialsKeyEnv))
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %v", config.CredentialsKeyEnv, err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("%s must be 32 hex encoded bytes", config.CredentialsKeyEnv)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,63 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+// Package download gets the dependencies of source packages for the play and frizz handlers.
+package download
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/dave/services"
+	"github.com/dave/services/getter/cache"
+	"github.com/dave/services/getter/get"
+	"github.com/dave/services/getter/gettermsg"
+	"github.com/dave/services/session"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/credentials"
+)
+
+// Dependencies gets the dependencies of the source packages, like the "go get" command. Private repos
+// are fetched with the credentials registered for the token in the request.
+func Dependencies(ctx context.Context, c *credentials.Credentials, shared *cache.Cache, s *session.Session, source map[string]map[string]string, req *http.Request, send func(message services.Message)) error {
+
+	// Send a message to the client that downloading step has started.
+	send(gettermsg.Downloading{Starting: true})
+
+	gitcache, _, err := c.Cache(ctx, req, shared)
+	if err != nil {
+		return err
+	}
+
+	gitreq := gitcache.NewRequest(false)
+	var paths []string
+	for path := range source {
+		paths = append(paths, path)
+	}
+	if err := gitreq.InitialiseFromHints(ctx, paths...); err != nil {
+		return err
+	}
+
+	// set insecure = true in local mode or it will fail if git repo has git protocol
+	insecure := config.LOCAL
+
+	// Start the download process - just like the "go get" command.
+	g := get.New(s, send, gitreq)
+	for path := range source {
+		if err := g.Get(ctx, path, false, insecure, false); err != nil {
+			return err
+		}
+	}
+
+	if err := gitreq.Close(ctx); err != nil {
+		return err
+	}
+
+	// Send a message to the client that downloading step has finished.
+	send(gettermsg.Downloading{Done: true})
+
+	return nil
+}
@@ -0,0 +1,222 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+// Package editor serves the completion and hover requests of the play and frizz editors. Requests are
+// sent while the user is typing, so sessions are cached with their downloaded and imported dependencies,
+// and the dependencies are only downloaded again when the imports change.
+package editor
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dave/services"
+	"github.com/dave/services/getter/cache"
+	"github.com/dave/services/session"
+	"gopkg.in/src-d/go-billy.v4"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/assets"
+	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/checker"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/credentials"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/download"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/servermsg"
+)
+
+type Editor struct {
+	cache       *cache.Cache
+	fileserver  services.Fileserver
+	credentials *credentials.Credentials
+
+	m       sync.Mutex
+	entries map[string]*list.Element
+	recent  *list.List // Entries, most recently used first
+}
+
+type entry struct {
+	key     string
+	user    string
+	tags    string
+	expires time.Time
+
+	m       sync.Mutex // Held while the session is in use
+	session *session.Session
+	loader  *checker.Loader
+	source  map[string]bool // Source packages of the session
+}
+
+func New(c *cache.Cache, fileserver services.Fileserver, credentials *credentials.Credentials) *Editor {
+	return &Editor{
+		cache:       c,
+		fileserver:  fileserver,
+		credentials: credentials,
+		entries:     map[string]*list.Element{},
+		recent:      list.New(),
+	}
+}
+
+// Complete returns the identifiers that complete the code at offset in file.
+func (e *Editor) Complete(ctx context.Context, tags []string, source map[string]map[string]string, path, file string, offset int, req *http.Request, send func(message services.Message)) ([]servermsg.Candidate, error) {
+	var candidates []servermsg.Candidate
+	err := e.with(ctx, tags, source, req, send, func(loader *checker.Loader) error {
+		var err error
+		candidates, err = loader.Complete(source, path, file, offset)
+		return err
+	})
+	return candidates, err
+}
+
+// Hover returns the signature and doc comment of the identifier at offset in file.
+func (e *Editor) Hover(ctx context.Context, tags []string, source map[string]map[string]string, path, file string, offset int, req *http.Request, send func(message services.Message)) (*servermsg.HoverInfo, error) {
+	var hover *servermsg.HoverInfo
+	err := e.with(ctx, tags, source, req, send, func(loader *checker.Loader) error {
+		var err error
+		hover, err = loader.Hover(source, path, file, offset)
+		return err
+	})
+	return hover, err
+}
+
+// with runs f with a loader for a session containing the source and its dependencies. A cached session
+// is used if there's one for the same user, tags and imports.
+func (e *Editor) with(ctx context.Context, tags []string, source map[string]map[string]string, req *http.Request, send func(message services.Message), f func(loader *checker.Loader) error) error {
+	user := credentials.Token(req)
+	en := e.get(key(user, tags, source), user, tagsKey(tags))
+
+	en.m.Lock()
+	defer en.m.Unlock()
+
+	if en.session != nil {
+		// The dependencies are already downloaded, so only the source is updated.
+		if err := en.session.SetSource(source); err != nil {
+			return err
+		}
+		return f(en.loader)
+	}
+
+	s := session.New(tags, assets.Assets, assets.Archives, e.fileserver, config.ValidExtensions)
+
+	if err := s.SetSource(source); err != nil {
+		return err
+	}
+
+	// The dependencies are type checked from source, so they must be downloaded.
+	if err := download.Dependencies(ctx, e.credentials, e.cache, s, source, req, send); err != nil {
+		return err
+	}
+
+	en.session = s
+	en.loader = checker.NewLoader(s.BuildContext(session.DefaultType, ""))
+	en.source = map[string]bool{}
+	for path := range source {
+		en.source[path] = true
+	}
+
+	return f(en.loader)
+}
+
+// Dependencies runs f with the GOPATH of the most recently used session of the user with the same tags,
+// while the session is locked. Nothing is downloaded: gopath is nil if there's no session. The source
+// packages of the session are in skip, so only its downloaded dependencies are used.
+func (e *Editor) Dependencies(req *http.Request, tags []string, f func(gopath billy.Filesystem, skip map[string]bool) error) error {
+	user, tk := credentials.Token(req), tagsKey(tags)
+
+	var en *entry
+	e.m.Lock()
+	for el := e.recent.Front(); el != nil; el = el.Next() {
+		if c := el.Value.(*entry); c.user == user && c.tags == tk && time.Now().Before(c.expires) {
+			en = c
+			break
+		}
+	}
+	e.m.Unlock()
+	if en == nil {
+		return f(nil, nil)
+	}
+
+	en.m.Lock()
+	defer en.m.Unlock()
+	if en.session == nil {
+		// The session failed to download its dependencies
+		return f(nil, nil)
+	}
+	return f(en.session.GoPath(), en.source)
+}
+
+// get returns the cache entry for key, adding a new entry if it's not found or has expired. The least
+// recently used entries are removed when there are more than config.EditorSessions.
+func (e *Editor) get(key, user, tags string) *entry {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	if el, ok := e.entries[key]; ok {
+		en := el.Value.(*entry)
+		if time.Now().Before(en.expires) {
+			en.expires = time.Now().Add(config.EditorSessionTime)
+			e.recent.MoveToFront(el)
+			return en
+		}
+		e.recent.Remove(el)
+		delete(e.entries, key)
+	}
+
+	en := &entry{key: key, user: user, tags: tags, expires: time.Now().Add(config.EditorSessionTime)}
+	e.entries[key] = e.recent.PushFront(en)
+
+	for e.recent.Len() > config.EditorSessions {
+		el := e.recent.Back()
+		e.recent.Remove(el)
+		delete(e.entries, el.Value.(*entry).key)
+	}
+
+	return en
+}
+
+// key identifies the sessions that can be reused for the source. Sessions aren't shared between users
+// with a token because the dependencies may have been downloaded with the user's credentials. Sessions
+// of anonymous users are shared, so the name of every source file is in the key: SetSource then replaces
+// all the files of the previous source, and none of another user's files are left in the session.
+func key(user string, tags []string, source map[string]map[string]string) string {
+
+	paths := map[string]bool{}
+	for path, files := range source {
+		paths[path] = true
+		for name, contents := range files {
+			paths[path+"/"+name] = true
+			if !strings.HasSuffix(name, ".go") {
+				continue
+			}
+			f, _ := parser.ParseFile(token.NewFileSet(), name, contents, parser.ImportsOnly)
+			if f == nil {
+				continue
+			}
+			for _, spec := range f.Imports {
+				paths[strings.Trim(spec.Path.Value, "\"`")] = true
+			}
+		}
+	}
+	var sorted []string
+	for path := range paths {
+		sorted = append(sorted, path)
+	}
+	sort.Strings(sorted)
+
+	return fmt.Sprintf("%q %s %q", user, tagsKey(tags), sorted)
+}
+
+// tagsKey identifies a set of build tags.
+func tagsKey(tags []string) string {
+	tags = append([]string(nil), tags...)
+	sort.Strings(tags)
+	return fmt.Sprintf("%q", tags)
+}
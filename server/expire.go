@@ -0,0 +1,35 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/play"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/store"
+)
+
+// expireShares removes expired playground shares every config.ShareExpiryInterval until the server
+// shuts down.
+func (h *Handler) expireShares() {
+	ticker := time.NewTicker(config.ShareExpiryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.shutdown:
+			return
+		case <-ticker.C:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), config.RequestTimeout)
+		if err := play.ExpireShares(ctx, h.Fileserver, h.Database, h.Lister); err != nil {
+			// ignore errors when logging an error
+			store.StoreError(ctx, h.Database, store.Error{Time: time.Now(), Error: err.Error()})
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,48 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package frizz
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/dave/services"
+	"github.com/dave/services/session"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/assets"
+	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/checker"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/download"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/frizz/messages"
+)
+
+func (h *Handler) Check(ctx context.Context, info messages.Check, req *http.Request, send func(message services.Message), receive chan services.Message) error {
+
+	s := session.New(info.Tags, assets.Assets, assets.Archives, h.Fileserver, config.ValidExtensions)
+
+	if err := s.SetSource(info.Source); err != nil {
+		return err
+	}
+
+	// The dependencies are type checked from source, so they must be downloaded.
+	if err := h.download(ctx, s, info.Source, req, send); err != nil {
+		return err
+	}
+
+	diagnostics, err := checker.Check(s.BuildContext(session.DefaultType, ""), info.Source)
+	if err != nil {
+		return err
+	}
+
+	send(messages.CheckComplete{Diagnostics: diagnostics})
+
+	return nil
+}
+
+// download gets the dependencies of the source packages, like the "go get" command.
+func (h *Handler) download(ctx context.Context, s *session.Session, source map[string]map[string]string, req *http.Request, send func(message services.Message)) error {
+	return download.Dependencies(ctx, h.Credentials, h.Cache, s, source, req, send)
+}
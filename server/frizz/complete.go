@@ -0,0 +1,39 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package frizz
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/dave/services"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/frizz/messages"
+)
+
+func (h *Handler) Complete(ctx context.Context, info messages.Complete, req *http.Request, send func(message services.Message), receive chan services.Message) error {
+
+	candidates, err := h.Editor.Complete(ctx, info.Tags, info.Source, info.Path, info.File, info.Offset, req, send)
+	if err != nil {
+		return err
+	}
+
+	send(messages.Completions{Candidates: candidates})
+
+	return nil
+}
+
+func (h *Handler) Hover(ctx context.Context, info messages.Hover, req *http.Request, send func(message services.Message), receive chan services.Message) error {
+
+	hover, err := h.Editor.Hover(ctx, info.Tags, info.Source, info.Path, info.File, info.Offset, req, send)
+	if err != nil {
+		return err
+	}
+
+	send(messages.HoverComplete{Info: hover})
+
+	return nil
+}
@@ -148,7 +148,8 @@ func writeType(buf *bytes.Buffer, typ Type, qf Qualifier, visited []Type) {
 			writeType(buf, typ, qf, visited)
 			empty = false
 		}
-		if t.AllMethods == nil || len(t.Methods) > len(t.AllMethods) {
+		// Converted interfaces are always complete, so AllMethods is nil for the empty interface.
+		if len(t.Methods) > len(t.AllMethods) {
 			if !empty {
 				buf.WriteByte(' ')
 			}
@@ -169,7 +170,7 @@ func writeType(buf *bytes.Buffer, typ Type, qf Qualifier, visited []Type) {
 		case SendRecv:
 			s = "chan "
 			// chan (<-chan T) requires parentheses
-			if c, _ := t.Elem.(*Chan); c.Dir == RecvOnly {
+			if c, _ := t.Elem.(*Chan); c != nil && c.Dir == RecvOnly {
 				parens = true
 			}
 		case SendOnly:
@@ -197,6 +198,12 @@ func writeType(buf *bytes.Buffer, typ Type, qf Qualifier, visited []Type) {
 		}
 		buf.WriteString(t.Name)
 
+	case *Reference:
+		if t.Path != "" {
+			writePackage(buf, t.Path, qf)
+		}
+		buf.WriteString(t.Name)
+
 	default:
 		// For externally defined implementations of Type.
 		buf.WriteString(t.String())
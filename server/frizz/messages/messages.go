@@ -27,11 +27,17 @@ func init() {
 
 	// Commands:
 	gob.Register(GetPackages{})
+	gob.Register(Check{})
+	gob.Register(Complete{})
+	gob.Register(Hover{})
 
 	// Data messages:
 	gob.Register(PackageIndex{})
 	gob.Register(Source{})
 	gob.Register(Objects{})
+	gob.Register(CheckComplete{})
+	gob.Register(Completions{})
+	gob.Register(HoverComplete{})
 
 	// Initialise types in deployermsg
 	deployermsg.RegisterTypes()
@@ -87,6 +93,46 @@ type Objects struct {
 	Standard bool
 }
 
+// Check is sent by the client to type check the source and run static analysis passes over it, so
+// problems can be shown before compiling.
+type Check struct {
+	Source map[string]map[string]string // Source packages: map[<package>]map[<filename>]<contents>
+	Tags   []string                     // Build tags
+}
+
+// CheckComplete is sent when the source has been checked.
+type CheckComplete struct {
+	Diagnostics []servermsg.Diagnostic
+}
+
+// Complete is sent by the client to get the identifiers that complete the code at Offset in File.
+type Complete struct {
+	Source map[string]map[string]string // Source packages: map[<package>]map[<filename>]<contents>
+	Tags   []string                     // Build tags
+	Path   string                       // Package containing the cursor
+	File   string                       // File containing the cursor
+	Offset int                          // Byte offset of the cursor
+}
+
+// Completions is sent in response to Complete.
+type Completions struct {
+	Candidates []servermsg.Candidate
+}
+
+// Hover is sent by the client to get the signature and doc comment of the identifier at Offset in File.
+type Hover struct {
+	Source map[string]map[string]string // Source packages: map[<package>]map[<filename>]<contents>
+	Tags   []string                     // Build tags
+	Path   string                       // Package containing the cursor
+	File   string                       // File containing the cursor
+	Offset int                          // Byte offset of the cursor
+}
+
+// HoverComplete is sent in response to Hover. Info is nil if there's no identifier at the cursor.
+type HoverComplete struct {
+	Info *servermsg.HoverInfo
+}
+
 func Marshal(in services.Message) ([]byte, int, error) {
 	p := Payload{in}
 	buf := &bytes.Buffer{}
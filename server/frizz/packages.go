@@ -57,7 +57,16 @@ func (h *Handler) Packages(ctx context.Context, info messages.GetPackages, req *
 		save = true
 	}
 
-	gitreq := h.Cache.NewRequest(save)
+	gitcache, private, err := h.Credentials.Cache(ctx, req, h.Cache)
+	if err != nil {
+		return err
+	}
+	if private {
+		// never save the getter hints when using stored credentials, or private repos would leak
+		save = false
+	}
+
+	gitreq := gitcache.NewRequest(save)
 	if err := gitreq.InitialiseFromHints(ctx, info.Path); err != nil {
 		return err
 	}
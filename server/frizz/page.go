@@ -8,7 +8,6 @@ package frizz
 import (
 	"context"
 	"errors"
-	"fmt"
 	"html/template"
 	"net/http"
 	"strings"
@@ -16,6 +15,7 @@ import (
 	"github.com/dave/services"
 
 	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/cdn"
 	"github.com/sniperkit/snk.fork.dave-jsgo/server/store"
 )
 
@@ -35,7 +35,7 @@ func Page(w http.ResponseWriter, req *http.Request, database services.Database)
 		if !found {
 			return errors.New("ed package not found")
 		}
-		url = fmt.Sprintf("https://pkg.jsgo.io/github.com/dave/frizz.%s.js", c.Min.Main)
+		url = cdn.PackageUrl("github.com/dave/frizz", c.Min.Main)
 	}
 
 	v := struct {
@@ -0,0 +1,211 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"html/template"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/play"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/store"
+)
+
+// AdminHandler shows the progress of the rebuild job, and starts it with a POST to /_admin/rebuild. It
+// also lists the most reported playground shares, which are blocked with a POST to /_admin/share/block.
+// Requests must have the token in the JSGO_ADMIN_TOKEN environment variable in an "Authorization: Bearer
+// <token>" header, or in the HttpOnly cookie set by posting the token to /_admin/login (the login form
+// is shown at /_admin/). The token is never put in URLs, so it isn't logged or leaked in referrers. The
+// admin page is disabled if the environment variable isn't set.
+func (h *Handler) AdminHandler(w http.ResponseWriter, req *http.Request) {
+	token := os.Getenv(config.AdminTokenEnv)
+	if token == "" {
+		http.NotFound(w, req)
+		return
+	}
+
+	if req.URL.Path == "/_admin/login" {
+		if req.Method != http.MethodPost {
+			http.Error(w, "method not allowed", 405)
+			return
+		}
+		if !equalToken(req.PostFormValue("token"), token) {
+			http.Error(w, "forbidden", 403)
+			return
+		}
+		// The cookie is SameSite, so other sites can't post the admin forms.
+		http.SetCookie(w, &http.Cookie{
+			Name:     adminCookie,
+			Value:    token,
+			Path:     "/_admin/",
+			HttpOnly: true,
+			Secure:   !config.LOCAL,
+			SameSite: http.SameSiteStrictMode,
+		})
+		http.Redirect(w, req, "/_admin/", http.StatusSeeOther)
+		return
+	}
+
+	if !adminAuthorized(req, token) {
+		if req.URL.Path == "/_admin/" && req.Method == http.MethodGet {
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(adminLogin))
+			return
+		}
+		http.Error(w, "forbidden", 403)
+		return
+	}
+
+	switch req.URL.Path {
+	case "/_admin/":
+		v := struct {
+			Available bool
+			Status    RebuildStatus
+			Reported  []store.ShareState
+		}{
+			Available: h.Lister != nil,
+			Status:    h.Rebuild.Status(),
+		}
+		if h.Lister != nil {
+			ctx, cancel := context.WithTimeout(req.Context(), config.PageTimeout)
+			defer cancel()
+			reported, err := h.Lister.ListReportedShares(ctx)
+			if err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
+			v.Reported = reported
+		}
+		w.Header().Set("Content-Type", "text/html")
+		if err := adminTemplate.Execute(w, v); err != nil {
+			http.Error(w, err.Error(), 500)
+		}
+	case "/_admin/rebuild":
+		if req.Method != http.MethodPost {
+			http.Error(w, "method not allowed", 405)
+			return
+		}
+		if err := h.StartRebuild(); err != nil {
+			http.Error(w, err.Error(), 409)
+			return
+		}
+		http.Redirect(w, req, "/_admin/", http.StatusSeeOther)
+	case "/_admin/share/block":
+		if req.Method != http.MethodPost {
+			http.Error(w, "method not allowed", 405)
+			return
+		}
+		ctx, cancel := context.WithTimeout(req.Context(), config.PageTimeout)
+		defer cancel()
+		if err := play.BlockShare(ctx, h.Fileserver, h.Database, req.FormValue("hash")); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		http.Redirect(w, req, "/_admin/", http.StatusSeeOther)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+const adminCookie = "jsgo-admin"
+
+// adminAuthorized returns true if the request has the admin token in the Authorization header or the
+// cookie set by /_admin/login.
+func adminAuthorized(req *http.Request, token string) bool {
+	if auth := req.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return equalToken(strings.TrimPrefix(auth, "Bearer "), token)
+	}
+	if c, err := req.Cookie(adminCookie); err == nil {
+		return equalToken(c.Value, token)
+	}
+	return false
+}
+
+func equalToken(supplied, token string) bool {
+	return subtle.ConstantTimeCompare([]byte(supplied), []byte(token)) == 1
+}
+
+const adminLogin = `<html>
+	<head>
+		<meta charset="utf-8">
+		<title>Admin</title>
+		<link href="https://maxcdn.bootstrapcdn.com/bootstrap/4.0.0/css/bootstrap.min.css" rel="stylesheet" integrity="sha384-Gn5384xqQ1aoWXA+058RXPxPg6fy4IWvTNh0E263XmFcJlSAwiGgFAW/dAiS6JXm" crossorigin="anonymous">
+	</head>
+	<body>
+		<div class="container">
+			<h3>Admin</h3>
+			<form method="post" action="/_admin/login" class="form-inline">
+				<input type="password" name="token" class="form-control mr-2" placeholder="Admin token">
+				<button type="submit" class="btn btn-primary">Log in</button>
+			</form>
+		</div>
+	</body>
+</html>`
+
+var adminTemplate = template.Must(template.New("admin").Parse(`<html>
+	<head>
+		<meta charset="utf-8">
+		<title>Admin</title>
+		<link href="https://maxcdn.bootstrapcdn.com/bootstrap/4.0.0/css/bootstrap.min.css" rel="stylesheet" integrity="sha384-Gn5384xqQ1aoWXA+058RXPxPg6fy4IWvTNh0E263XmFcJlSAwiGgFAW/dAiS6JXm" crossorigin="anonymous">
+		{{ if .Status.Running }}<meta http-equiv="refresh" content="5">{{ end }}
+	</head>
+	<body>
+		<div class="container">
+			<h3>Rebuild</h3>
+			<p>Recompiles stored packages that reference outdated standard library hashes.</p>
+			{{ if not .Available }}
+				<p>Rebuild is not available because the database can't list packages.</p>
+			{{ else }}
+				<table class="table table-sm">
+					<tr><th>Running</th><td>{{ .Status.Running }}</td></tr>
+					<tr><th>Started</th><td>{{ if not .Status.Started.IsZero }}{{ .Status.Started }}{{ end }}</td></tr>
+					<tr><th>Finished</th><td>{{ if not .Status.Finished.IsZero }}{{ .Status.Finished }}{{ end }}</td></tr>
+					<tr><th>Current</th><td>{{ .Status.Current }}</td></tr>
+					<tr><th>Checked</th><td>{{ .Status.Checked }} / {{ .Status.Total }}</td></tr>
+					<tr><th>Outdated</th><td>{{ .Status.Outdated }}</td></tr>
+					<tr><th>Rebuilt</th><td>{{ .Status.Rebuilt }}</td></tr>
+					<tr><th>Failed</th><td>{{ .Status.Failed }}</td></tr>
+				</table>
+				{{ range .Status.Errors }}
+					<div class="alert alert-danger">{{ . }}</div>
+				{{ end }}
+				{{ if not .Status.Running }}
+					<form method="post" action="/_admin/rebuild">
+						<button type="submit" class="btn btn-primary">Start rebuild</button>
+					</form>
+				{{ end }}
+			{{ end }}
+			<h3>Reported shares</h3>
+			{{ if not .Available }}
+				<p>Reported shares are not available because the database can't list shares.</p>
+			{{ else }}
+				<table class="table table-sm">
+					<tr><th>Share</th><th>Reports</th><th>Reasons</th><th></th></tr>
+					{{ range .Reported }}
+						<tr>
+							<td><code>{{ .Hash }}</code></td>
+							<td>{{ .ReportCount }}</td>
+							<td>{{ range .Reports }}<div>{{ .Reason }}</div>{{ end }}</td>
+							<td>{{ if not .Deleted }}<form method="post" action="/_admin/share/block">
+								<input type="hidden" name="hash" value="{{ .Hash }}">
+								<button type="submit" class="btn btn-danger btn-sm">Block</button>
+							</form>{{ end }}</td>
+						</tr>
+					{{ end }}
+				</table>
+			{{ end }}
+			<form method="post" action="/_admin/share/block" class="form-inline">
+				<input type="text" name="hash" class="form-control mr-2" placeholder="Share hash">
+				<button type="submit" class="btn btn-danger">Block share</button>
+			</form>
+		</div>
+	</body>
+</html>`))
@@ -0,0 +1,49 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+)
+
+// CredentialsHandler registers an access token for private repositories. The request body is a JSON
+// object with Prefix (e.g. "github.com/my-org"), Username and Secret, and optionally the API token to
+// add the credential to. The API token is returned, and should be sent with compile, play and frizz
+// requests in the Authorization header (see credentials.Token).
+func (h *Handler) CredentialsHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), config.PageTimeout)
+	defer cancel()
+
+	var body struct {
+		Token    string
+		Prefix   string
+		Username string
+		Secret   string
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	token, err := h.Credentials.Register(ctx, body.Token, body.Prefix, body.Username, body.Secret)
+	if err != nil {
+		h.storeError(ctx, err, req)
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct{ Token string }{token})
+}
@@ -0,0 +1,28 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/jsgo"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/play"
+)
+
+func (h *Handler) ExportHandler(w http.ResponseWriter, req *http.Request) {
+	switch getPage(req) {
+	case PlayPage:
+		play.Export(w, req, h.Database, h.Fileserver)
+		return
+	case JsgoPage:
+		jsgo.Export(w, req, h.Database, h.Fileserver)
+		return
+	default:
+		http.Error(w, fmt.Sprintf("unknown host %s", req.Host), 500)
+		return
+	}
+}
@@ -0,0 +1,28 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/jsgo"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/play"
+)
+
+func (h *Handler) ManifestHandler(w http.ResponseWriter, req *http.Request) {
+	switch getPage(req) {
+	case PlayPage:
+		play.Manifest(w, req, h.Database)
+		return
+	case JsgoPage:
+		jsgo.Manifest(w, req, h.Database)
+		return
+	default:
+		http.Error(w, fmt.Sprintf("unknown host %s", req.Host), 500)
+		return
+	}
+}
@@ -0,0 +1,60 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/play"
+)
+
+// ShareHandler deletes and reports playground shares. A POST to /_share/delete with a JSON object with
+// Hash and Token deletes the share, if Token is the delete token returned when it was shared. A POST to
+// /_share/report with Hash and Reason reports the share for abuse.
+func (h *Handler) ShareHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), config.PageTimeout)
+	defer cancel()
+
+	var body struct {
+		Hash   string
+		Token  string
+		Reason string
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, req.Body, config.MaxShareRequestSize)).Decode(&body); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	var err error
+	switch req.URL.Path {
+	case "/_share/delete":
+		err = play.DeleteShare(ctx, h.Fileserver, h.Database, body.Hash, body.Token)
+	case "/_share/report":
+		err = play.ReportShare(ctx, h.Fileserver, h.Database, body.Hash, body.Reason, req.Header.Get("X-Forwarded-For"))
+	default:
+		http.NotFound(w, req)
+		return
+	}
+	if err == play.ErrShareNotFound {
+		http.Error(w, err.Error(), 404)
+		return
+	}
+	if err != nil {
+		h.storeError(ctx, err, req)
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
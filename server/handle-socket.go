@@ -19,6 +19,8 @@ import (
 	"github.com/dave/services/tracker"
 	"github.com/gorilla/websocket"
 
+	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/credentials"
 	"github.com/sniperkit/snk.fork.dave-jsgo/server/servermsg"
 )
 
@@ -52,7 +54,12 @@ func (h *Handler) SocketHandler(s SocketHandlerInterface) func(w http.ResponseWr
 			cancel()
 		}()
 
-		conn, err := upgrader.Upgrade(w, req, nil)
+		var header http.Header
+		if protocol := credentials.TokenSubprotocol(req); protocol != "" {
+			header = http.Header{"Sec-Websocket-Protocol": {protocol}}
+		}
+
+		conn, err := upgrader.Upgrade(w, req, header)
 		if err != nil {
 			h.storeError(ctx, fmt.Errorf("upgrading request to websocket: %v", err), req)
 			return
@@ -122,6 +129,8 @@ func (h *Handler) SocketHandler(s SocketHandlerInterface) func(w http.ResponseWr
 			defer func() {
 				cancel()
 			}()
+			// Limit the message size, so large messages are rejected before they are buffered.
+			conn.SetReadLimit(config.WebsocketReadLimit)
 			conn.SetReadDeadline(time.Now().Add(s.WebsocketPongTimeout()))
 			conn.SetPongHandler(func(string) error {
 				conn.SetReadDeadline(time.Now().Add(s.WebsocketPongTimeout()))
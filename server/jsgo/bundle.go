@@ -0,0 +1,148 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package jsgo
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"text/template"
+
+	"github.com/dave/services"
+	"github.com/dave/services/builder"
+	"github.com/dave/services/constor"
+	"github.com/dave/services/fsutil"
+	"github.com/dave/services/session"
+	"github.com/gopherjs/gopherjs/compiler"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/assets"
+	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/store"
+)
+
+// Bundle creates a single self-contained JS file containing the prelude and every package, and
+// stores it in the pkg bucket as <path>.bundle.<hash>.js. The hash is returned.
+func (h *Handler) Bundle(ctx context.Context, s *session.Session, files *compileFiles, path string, contents store.CompileContents, min, dce bool, send func(services.Message)) (string, error) {
+
+	var js []byte
+	var err error
+	if dce {
+		js, err = h.bundleDce(ctx, s, path, min)
+	} else {
+		js, err = h.bundleConcat(ctx, files, path, contents)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	sha := sha1.New()
+	if _, err := sha.Write(js); err != nil {
+		return "", err
+	}
+	hash := fmt.Sprintf("%x", sha.Sum(nil))
+
+	var minified = " (un-minified)"
+	if min {
+		minified = " (minified)"
+	}
+
+	storer := constor.New(ctx, h.Fileserver, send, config.ConcurrentStorageUploads)
+	defer storer.Close()
+	storer.Add(constor.Item{
+		Message:   "bundle" + minified,
+		Name:      fmt.Sprintf("%s.bundle.%s.js", path, hash), // Note: hash is a string
+		Contents:  js,
+		Bucket:    config.Bucket[config.Pkg],
+		Mime:      constor.MimeJs,
+		Count:     true,
+		Immutable: true,
+		Send:      true,
+	})
+	if err := storer.Wait(); err != nil {
+		return "", err
+	}
+
+	return hash, nil
+}
+
+// bundleConcat joins the package files that the deployer has stored in the pkg bucket (the first
+// package is the prelude), and appends the same start-up code that the loader runs once every
+// package has loaded.
+func (h *Handler) bundleConcat(ctx context.Context, files *compileFiles, path string, contents store.CompileContents) ([]byte, error) {
+	packages, err := files.readPackages(ctx, contents.Packages)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, p := range contents.Packages {
+		paths = append(paths, p.Path)
+	}
+	pathsJson, err := json.Marshal(paths)
+	if err != nil {
+		return nil, err
+	}
+	pathJson, err := json.Marshal(path)
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	buf.WriteString("\"use strict\";\n(function() {\nvar $mainPkg, $load = {};\n")
+	for _, b := range packages {
+		buf.Write(b)
+		buf.WriteString("\n")
+	}
+	if err := bundleFooterTemplate.Execute(buf, struct {
+		Path     string
+		Packages string
+	}{
+		Path:     string(pathJson),
+		Packages: string(pathsJson),
+	}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// bundleDce compiles the program using GopherJS dead code elimination. The standard library archives
+// in assets.Archives are stripped, so we use a new session without them, and copy the downloaded
+// source from the original session.
+func (h *Handler) bundleDce(ctx context.Context, original *session.Session, path string, min bool) ([]byte, error) {
+	s := session.New(nil, assets.Assets, nil, h.Fileserver, config.ValidExtensions)
+	if err := fsutil.Copy(s.GoPath(), "/gopath", original.GoPath(), "/gopath"); err != nil {
+		return nil, err
+	}
+	b := builder.New(s, &builder.Options{Unvendor: true, Minify: min})
+	_, archive, err := b.BuildImportPath(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	deps, err := compiler.ImportDependencies(archive, func(path string) (*compiler.Archive, error) {
+		_, archive, err := b.BuildImportPath(ctx, path)
+		return archive, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	if err := compiler.WriteProgramCode(deps, &compiler.SourceMapFilter{Writer: buf}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var bundleFooterTemplate = template.Must(template.New("main").Parse(`var $bundled = {{ .Packages }};
+for (var i = 0; i < $bundled.length; i++) {
+	$load[$bundled[i]]();
+}
+$mainPkg = $packages[{{ .Path }}];
+$synthesizeMethods();
+$packages["runtime"].$init();
+$go($mainPkg.$init, []);
+$flushConsole();
+}).call(this);
+`))
@@ -17,34 +17,55 @@ import (
 	"github.com/dave/services/getter/get"
 	"github.com/dave/services/getter/gettermsg"
 	"github.com/dave/services/session"
+	"github.com/gopherjs/gopherjs/compiler"
 
 	"github.com/sniperkit/snk.fork.dave-jsgo/assets"
 	"github.com/sniperkit/snk.fork.dave-jsgo/assets/std"
 	"github.com/sniperkit/snk.fork.dave-jsgo/config"
 	"github.com/sniperkit/snk.fork.dave-jsgo/server/jsgo/messages"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/serverconfig"
 	"github.com/sniperkit/snk.fork.dave-jsgo/server/servermsg"
 	"github.com/sniperkit/snk.fork.dave-jsgo/server/store"
 )
 
 func (h *Handler) Compile(ctx context.Context, info messages.Compile, req *http.Request, send func(services.Message), receive chan services.Message) error {
 
-	path := info.Path
+	// Vanity import paths are resolved here rather than when the page is requested, so the lookup only
+	// happens for compile jobs, which are queued.
+	var paths []string
+	for _, path := range append([]string{info.Path}, info.Paths...) {
+		paths = append(paths, resolveVanity(ctx, path))
+	}
 
-	s := session.New(nil, assets.Assets, assets.Archives, h.Fileserver, config.ValidExtensions)
+	// Several main packages may be compiled in the same job. The shared packages are only uploaded once,
+	// and the files are kept for the steps after each deploy.
+	files := newCompileFiles(h.Fileserver)
+	s := session.New(nil, assets.Assets, assets.Archives, newDedupFileserver(files), config.ValidExtensions)
 
 	// Send a message to the client that downloading step has started.
 	send(gettermsg.Downloading{Starting: true})
 
-	gitreq := h.Cache.NewRequest(true)
-	if err := gitreq.InitialiseFromHints(ctx, path); err != nil {
+	// Requests with an API token may use stored credentials to fetch private repos. Hints are not
+	// saved for these requests.
+	gitcache, private, err := h.Credentials.Cache(ctx, req, h.Cache)
+	if err != nil {
 		return err
 	}
 
+	gitreq := gitcache.NewRequest(!private)
+	for _, path := range paths {
+		if err := gitreq.InitialiseFromHints(ctx, strings.TrimSuffix(path, "/...")); err != nil {
+			return err
+		}
+	}
+
 	// set insecure = true in local mode or it will fail if git repo has git protocol
 	insecure := config.LOCAL
 
-	// Start the download process - just like the "go get" command.
-	if err := get.New(s, send, gitreq).Get(ctx, path, false, insecure, false); err != nil {
+	// Start the download process - just like the "go get" command. The source is downloaded once for
+	// all the main packages.
+	mains, err := getMains(ctx, s, get.New(s, send, gitreq), paths, insecure)
+	if err != nil {
 		return err
 	}
 
@@ -55,31 +76,189 @@ func (h *Handler) Compile(ctx context.Context, info messages.Compile, req *http.
 	// Send a message to the client that downloading step has finished.
 	send(gettermsg.Downloading{Done: true})
 
+	for _, path := range mains {
+		if err := h.deploy(ctx, s, files, path, info, private, req, send); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deploy compiles the main package at path, stores the output and sends a Complete message. Private
+// packages (compiled with stored credentials) aren't published at jsgo.io/<path> or recorded in the
+// database, so the index pages are stored by hash.
+func (h *Handler) deploy(ctx context.Context, s *session.Session, files *compileFiles, path string, info messages.Compile, private bool, req *http.Request, send func(services.Message)) error {
+
+	index := deployer.PathIndex
+	if private {
+		index = deployer.HashIndex
+	}
+
 	// Start the compile process - this compiles to JS and sends the files to a GCS bucket.
-	output, err := deployer.New(s, send, std.Index, std.Prelude, config.DeployerConfig).Deploy(ctx, path, deployer.PathIndex, map[bool]bool{true: true, false: true})
+	output, err := deployer.New(s, send, std.Index, std.Prelude, serverconfig.DeployerConfig).Deploy(ctx, path, index, map[bool]bool{true: true, false: true})
 	if err != nil {
 		return err
 	}
 
+	contents := map[bool]store.CompileContents{
+		true:  getCompileContents(output[true], true),
+		false: getCompileContents(output[false], false),
+	}
+	if private {
+		for _, min := range []bool{true, false} {
+			c := contents[min]
+			c.Index = fmt.Sprintf("%x", output[min].IndexHash)
+			contents[min] = c
+		}
+	}
+
+	// The source maps and modules need the archives of every package.
+	archives := map[bool][]*compiler.Archive{}
+	if info.SourceMaps || info.Modules {
+		for _, min := range []bool{true, false} {
+			if archives[min], err = buildArchives(ctx, s, path, min); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, min := range []bool{true, false} {
+		c := contents[min]
+		deployed := c.Main
+		if info.SourceMaps {
+			if err := h.SourceMaps(ctx, s, files, archives[min], path, &c, min, info.SourcesContent, send); err != nil {
+				return err
+			}
+		}
+		if err := h.AddSizes(ctx, files, &c); err != nil {
+			return err
+		}
+		if err := h.AddIntegrity(ctx, files, path, &c, min, send); err != nil {
+			return err
+		}
+		if err := h.AddIndex(ctx, path, deployed, &c, min, info.ServiceWorker, send); err != nil {
+			return err
+		}
+		contents[min] = c
+	}
+
+	if info.Bundle {
+		for _, min := range []bool{true, false} {
+			c := contents[min]
+			hash, err := h.Bundle(ctx, s, files, path, c, min, info.Dce, send)
+			if err != nil {
+				return err
+			}
+			c.Bundle = hash
+			contents[min] = c
+		}
+	}
+
+	var verify *messages.Verify
+	if info.Verify {
+		if verify, err = h.Verify(ctx, s, path, output, send); err != nil {
+			return err
+		}
+	}
+
+	if info.Modules {
+		for _, min := range []bool{true, false} {
+			c := contents[min]
+			if err := h.Modules(ctx, files, archives[min], path, &c, min, send); err != nil {
+				return err
+			}
+			contents[min] = c
+		}
+	}
+
+	complete := messages.Complete{
+		Path:         path,
+		Short:        strings.TrimPrefix(path, "github.com/"),
+		HashMin:      contents[true].Main,
+		HashMax:      contents[false].Main,
+		IntegrityMin: contents[true].Integrity,
+		IntegrityMax: contents[false].Integrity,
+		BundleMin:    contents[true].Bundle,
+		BundleMax:    contents[false].Bundle,
+		ModuleMin:    contents[true].Module,
+		ModuleMax:    contents[false].Module,
+		PackagesMin:  getCompletePackages(contents[true]),
+		PackagesMax:  getCompletePackages(contents[false]),
+		Verify:       verify,
+	}
+
+	if private {
+		complete.Private = true
+		complete.IndexMin = contents[true].Index
+		complete.IndexMax = contents[false].Index
+		send(complete)
+		return nil
+	}
+
+	if err := h.AddAliases(ctx, path, send); err != nil {
+		return err
+	}
+
+	// Compare with the previous compile before it's overwritten.
+	found, previous, err := store.Package(ctx, h.Database, path)
+	if err != nil {
+		return err
+	}
+	diff := map[bool]*messages.Diff{true: {}, false: {}}
+	if found {
+		for _, min := range []bool{true, false} {
+			p := previous.Max
+			if min {
+				p = previous.Min
+			}
+			// The diff is only informational, so the compile shouldn't fail if e.g. the previous files
+			// have been removed.
+			if d, err := h.getDiff(ctx, files, path, p, contents[min]); err == nil {
+				diff[min] = d
+			}
+		}
+	}
+
 	// Logs the success in the datastore
-	h.storeCompile(ctx, send, path, req, output)
+	h.storeCompile(ctx, send, path, info, req, contents)
 
 	// Send a message to the client that the process has successfully finished
-	send(messages.Complete{
-		Path:    path,
-		Short:   strings.TrimPrefix(path, "github.com/"),
-		HashMin: fmt.Sprintf("%x", output[true].MainHash),
-		HashMax: fmt.Sprintf("%x", output[false].MainHash),
-	})
+	complete.DiffMin = diff[true]
+	complete.DiffMax = diff[false]
+	send(complete)
 	return nil
 }
 
-func (h *Handler) storeCompile(ctx context.Context, send func(services.Message), path string, req *http.Request, output map[bool]*deployer.DeployOutput) {
+func getCompletePackages(c store.CompileContents) []messages.Package {
+	var packages []messages.Package
+	for _, p := range c.Packages {
+		packages = append(packages, messages.Package{
+			Path:      p.Path,
+			Hash:      p.Hash,
+			Standard:  p.Standard,
+			Integrity: p.Integrity,
+			Size:      p.Size,
+			Gzip:      p.Gzip,
+		})
+	}
+	return packages
+}
+
+func (h *Handler) storeCompile(ctx context.Context, send func(services.Message), path string, info messages.Compile, req *http.Request, contents map[bool]store.CompileContents) {
 	data := store.CompileData{
-		Path:    path,
-		Time:    time.Now(),
-		Min:     getCompileContents(output[true], true),
-		Max:     getCompileContents(output[false], false),
+		Path: path,
+		Time: time.Now(),
+		Min:  contents[true],
+		Max:  contents[false],
+		Options: store.CompileOptions{
+			Bundle:         info.Bundle,
+			Dce:            info.Dce,
+			SourceMaps:     info.SourceMaps,
+			SourcesContent: info.SourcesContent,
+			Verify:         info.Verify,
+			ServiceWorker:  info.ServiceWorker,
+			Modules:        info.Modules,
+		},
 		Ip:      req.Header.Get("X-Forwarded-For"),
 		Success: true,
 	}
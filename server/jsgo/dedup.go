@@ -0,0 +1,65 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package jsgo
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/dave/services"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+)
+
+// dedupFileserver wraps a Fileserver so that each file in the pkg bucket is only written once per job.
+// Package files are named by their content hash, so when several main packages are compiled in the
+// same job the packages they share are only uploaded for the first.
+type dedupFileserver struct {
+	services.Fileserver
+	m       sync.Mutex
+	written map[string]bool
+}
+
+func newDedupFileserver(fileserver services.Fileserver) *dedupFileserver {
+	return &dedupFileserver{
+		Fileserver: fileserver,
+		written:    map[string]bool{},
+	}
+}
+
+func (d *dedupFileserver) Exists(ctx context.Context, bucket, name string) (bool, error) {
+	if bucket == config.Bucket[config.Pkg] {
+		d.m.Lock()
+		written := d.written[name]
+		d.m.Unlock()
+		if written {
+			return true, nil
+		}
+	}
+	return d.Fileserver.Exists(ctx, bucket, name)
+}
+
+func (d *dedupFileserver) Write(ctx context.Context, bucket, name string, reader io.Reader, overwrite bool, mimeType, cacheControl string) (saved bool, err error) {
+	if bucket != config.Bucket[config.Pkg] {
+		return d.Fileserver.Write(ctx, bucket, name, reader, overwrite, mimeType, cacheControl)
+	}
+	d.m.Lock()
+	written := d.written[name]
+	d.written[name] = true
+	d.m.Unlock()
+	if written {
+		return false, nil
+	}
+	saved, err = d.Fileserver.Write(ctx, bucket, name, reader, overwrite, mimeType, cacheControl)
+	if err != nil {
+		// allow a retry if the write failed
+		d.m.Lock()
+		delete(d.written, name)
+		d.m.Unlock()
+	}
+	return saved, err
+}
@@ -0,0 +1,104 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package jsgo
+
+import (
+	"context"
+	"sort"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/cdn"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/jsgo/messages"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/store"
+)
+
+// getDiff compares the packages with the previous compile of the same path, and estimates the download
+// for a new visitor (all the packages and the loader) and for a returning visitor that has the previous
+// version in the browser cache.
+func (h *Handler) getDiff(ctx context.Context, files *compileFiles, path string, previous, current store.CompileContents) (*messages.Diff, error) {
+
+	before := map[string]store.CompilePackage{}
+	for _, p := range previous.Packages {
+		before[p.Path] = p
+	}
+	after := map[string]store.CompilePackage{}
+	for _, p := range current.Packages {
+		after[p.Path] = p
+	}
+
+	// We need the sizes of all the current packages, and the previous packages that have been removed or
+	// changed.
+	measure := append([]store.CompilePackage(nil), current.Packages...)
+	for _, p := range previous.Packages {
+		if a, ok := after[p.Path]; !ok || a.Hash != p.Hash {
+			measure = append(measure, p)
+		}
+	}
+	sizes, err := packageSizes(ctx, files, measure)
+	if err != nil {
+		return nil, err
+	}
+
+	loader, err := files.read(ctx, cdn.LoaderName(path, current.Main))
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &messages.Diff{
+		Previous: true,
+		New:      len(loader),
+	}
+	if current.Main != previous.Main {
+		diff.Returning += len(loader)
+	}
+	for _, p := range current.Packages {
+		size := sizes[cdn.PackageName(p.Path, p.Hash)]
+		diff.New += size
+		b, ok := before[p.Path]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, messages.DiffPackage{Path: p.Path, Size: size})
+			diff.Returning += size
+		case b.Hash != p.Hash:
+			diff.Changed = append(diff.Changed, messages.DiffPackage{Path: p.Path, Size: size, PreviousSize: sizes[cdn.PackageName(b.Path, b.Hash)]})
+			diff.Returning += size
+		}
+	}
+	for _, p := range previous.Packages {
+		if _, ok := after[p.Path]; !ok {
+			diff.Removed = append(diff.Removed, messages.DiffPackage{Path: p.Path, PreviousSize: sizes[cdn.PackageName(p.Path, p.Hash)]})
+		}
+	}
+	for _, list := range [][]messages.DiffPackage{diff.Added, diff.Changed, diff.Removed} {
+		sort.Slice(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+	}
+	return diff, nil
+}
+
+// packageSizes returns the size of the compiled JS of each package, keyed by file name. Stored sizes
+// are used if available, otherwise (e.g. for compiles stored before sizes were recorded) the files are
+// read.
+func packageSizes(ctx context.Context, files *compileFiles, packages []store.CompilePackage) (map[string]int, error) {
+	sizes := map[string]int{}
+	var read []store.CompilePackage
+	for _, p := range packages {
+		if p.Size > 0 {
+			sizes[cdn.PackageName(p.Path, p.Hash)] = p.Size
+			continue
+		}
+		read = append(read, p)
+	}
+	if len(read) == 0 {
+		return sizes, nil
+	}
+	contents, err := files.readPackages(ctx, read)
+	if err != nil {
+		return nil, err
+	}
+	for i, p := range read {
+		sizes[cdn.PackageName(p.Path, p.Hash)] = len(contents[i])
+	}
+	return sizes, nil
+}
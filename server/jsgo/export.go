@@ -0,0 +1,65 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package jsgo
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dave/services"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/cdn"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/store"
+)
+
+// Export serves a zip archive of a compiled package at /_export/<path>.zip (or <path>$max.zip for the
+// un-minified version), so the app can be hosted without any dependency on pkg.jsgo.io.
+func Export(w http.ResponseWriter, req *http.Request, database services.Database, fileserver services.Fileserver) {
+
+	ctx, cancel := context.WithTimeout(req.Context(), config.ExportTimeout)
+	defer cancel()
+
+	path := strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, "/_export/"), ".zip")
+
+	min := true
+	if strings.HasSuffix(path, "$max") {
+		min = false
+		path = strings.TrimSuffix(path, "$max")
+	}
+
+	path = resolvePath(path)
+
+	found, data, err := store.Package(ctx, database, path)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	if !found {
+		http.Error(w, fmt.Sprintf("%s not found", path), 404)
+		return
+	}
+
+	contents := data.Max
+	if min {
+		contents = data.Min
+	}
+	index := indexNames(path, min)[0]
+
+	buf := &bytes.Buffer{}
+	if err := cdn.Export(ctx, fileserver, buf, index, cdn.LoaderName(path, contents.Main), contents.Packages); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	name := path[strings.LastIndex(path, "/")+1:]
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".zip"))
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,174 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package jsgo
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/dave/services"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/cdn"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/store"
+)
+
+// compileFiles wraps the Fileserver used by a compile job, and keeps the contents of each file written
+// to the pkg bucket, so the steps after the deploy (sizes, integrity, bundles, modules, source maps and
+// the diff) don't read them back. Files that weren't written by the job (e.g. packages stored by an
+// earlier compile) are read once.
+type compileFiles struct {
+	services.Fileserver
+	m     sync.Mutex
+	files map[string][]byte   // Contents of files in the pkg bucket, by name
+	infos map[string]fileInfo // Sizes and digests of files in the pkg bucket, by name
+}
+
+// fileInfo is the size, gzipped size and Subresource Integrity digest of a file.
+type fileInfo struct {
+	size      int
+	gzip      int
+	integrity string
+}
+
+// standardInfos keeps the fileInfo of standard library packages and the prelude between compiles. They
+// are named by hash and only change when the assets are rebuilt, so the map stays small.
+var standardInfos = struct {
+	sync.Mutex
+	infos map[string]fileInfo
+}{infos: map[string]fileInfo{}}
+
+func newCompileFiles(fileserver services.Fileserver) *compileFiles {
+	return &compileFiles{
+		Fileserver: fileserver,
+		files:      map[string][]byte{},
+		infos:      map[string]fileInfo{},
+	}
+}
+
+func (f *compileFiles) Write(ctx context.Context, bucket, name string, reader io.Reader, overwrite bool, mimeType, cacheControl string) (saved bool, err error) {
+	if bucket != config.Bucket[config.Pkg] {
+		return f.Fileserver.Write(ctx, bucket, name, reader, overwrite, mimeType, cacheControl)
+	}
+	b, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return false, err
+	}
+	f.add(name, b)
+	return f.Fileserver.Write(ctx, bucket, name, bytes.NewReader(b), overwrite, mimeType, cacheControl)
+}
+
+// add records the contents of a file in the pkg bucket that was stored without going through Write.
+func (f *compileFiles) add(name string, contents []byte) {
+	f.m.Lock()
+	defer f.m.Unlock()
+	f.files[name] = contents
+}
+
+// read returns the contents of a file in the pkg bucket.
+func (f *compileFiles) read(ctx context.Context, name string) ([]byte, error) {
+	f.m.Lock()
+	b, ok := f.files[name]
+	f.m.Unlock()
+	if ok {
+		return b, nil
+	}
+	b, err := cdn.Read(ctx, f.Fileserver, config.Bucket[config.Pkg], name)
+	if err != nil {
+		return nil, err
+	}
+	f.add(name, b)
+	return b, nil
+}
+
+// readPackages returns the compiled JS for packages, in the same order as packages. Files that haven't
+// been written or read yet are read concurrently.
+func (f *compileFiles) readPackages(ctx context.Context, packages []store.CompilePackage) ([][]byte, error) {
+	var missing []store.CompilePackage
+	f.m.Lock()
+	for _, p := range packages {
+		if _, ok := f.files[cdn.PackageName(p.Path, p.Hash)]; !ok {
+			missing = append(missing, p)
+		}
+	}
+	f.m.Unlock()
+	if len(missing) > 0 {
+		contents, err := cdn.ReadPackages(ctx, f.Fileserver, missing)
+		if err != nil {
+			return nil, err
+		}
+		for i, p := range missing {
+			f.add(cdn.PackageName(p.Path, p.Hash), contents[i])
+		}
+	}
+	f.m.Lock()
+	defer f.m.Unlock()
+	contents := make([][]byte, len(packages))
+	for i, p := range packages {
+		contents[i] = f.files[cdn.PackageName(p.Path, p.Hash)]
+	}
+	return contents, nil
+}
+
+// packageInfos returns the fileInfo of each package, in the same order as packages. Each file is only
+// measured once per job, and standard library packages only once per process.
+func (f *compileFiles) packageInfos(ctx context.Context, packages []store.CompilePackage) ([]fileInfo, error) {
+	infos := make([]fileInfo, len(packages))
+	found := make([]bool, len(packages))
+	var missing []store.CompilePackage
+	standardInfos.Lock()
+	f.m.Lock()
+	for i, p := range packages {
+		name := cdn.PackageName(p.Path, p.Hash)
+		if p.Standard {
+			infos[i], found[i] = standardInfos.infos[name]
+		} else {
+			infos[i], found[i] = f.infos[name]
+		}
+		if !found[i] {
+			missing = append(missing, p)
+		}
+	}
+	f.m.Unlock()
+	standardInfos.Unlock()
+	if len(missing) == 0 {
+		return infos, nil
+	}
+
+	contents, err := f.readPackages(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	measured := map[string]fileInfo{}
+	for i, p := range missing {
+		measured[cdn.PackageName(p.Path, p.Hash)] = fileInfo{
+			size:      len(contents[i]),
+			gzip:      cdn.GzipSize(contents[i]),
+			integrity: cdn.Integrity(contents[i]),
+		}
+	}
+
+	standardInfos.Lock()
+	f.m.Lock()
+	defer standardInfos.Unlock()
+	defer f.m.Unlock()
+	for i, p := range packages {
+		if found[i] {
+			continue
+		}
+		name := cdn.PackageName(p.Path, p.Hash)
+		infos[i] = measured[name]
+		if p.Standard {
+			standardInfos.infos[name] = infos[i]
+		} else {
+			f.infos[name] = infos[i]
+		}
+	}
+	return infos, nil
+}
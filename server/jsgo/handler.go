@@ -19,14 +19,16 @@ import (
 	"github.com/dave/services/tracker"
 
 	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/credentials"
 	"github.com/sniperkit/snk.fork.dave-jsgo/server/jsgo/messages"
 	"github.com/sniperkit/snk.fork.dave-jsgo/server/store"
 )
 
 type Handler struct {
-	Cache      *cache.Cache
-	Fileserver services.Fileserver
-	Database   services.Database
+	Cache       *cache.Cache
+	Fileserver  services.Fileserver
+	Database    services.Database
+	Credentials *credentials.Credentials
 }
 
 func (h *Handler) Handle(ctx context.Context, req *http.Request, send func(message services.Message), receive chan services.Message, tj *tracker.Job) error {
@@ -36,6 +38,8 @@ func (h *Handler) Handle(ctx context.Context, req *http.Request, send func(messa
 		switch m := m.(type) {
 		case messages.Compile:
 			return h.Compile(ctx, m, req, send, receive)
+		case messages.Upload:
+			return h.Upload(ctx, m, req, send, receive)
 		default:
 			return fmt.Errorf("invalid init message %T", m)
 		}
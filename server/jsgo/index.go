@@ -0,0 +1,137 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package jsgo
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/dave/services"
+	"github.com/dave/services/constor"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/cdn"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/store"
+)
+
+// AddIndex rewrites the index pages the deployer created for the package: the loader tag loads the
+// loader from AddIntegrity with its digest, and the head has preload links for the packages and (if
+// serviceWorker is set) registers a service worker. deployed is the hash of the loader the deployer
+// stored. Everything added by an earlier rewrite is replaced, so the pages can be rewritten more than
+// once.
+//
+// Pages of private packages are named by hash and immutable, so they are not overwritten. The rewritten
+// page is stored under its own hash, and contents.Index is updated.
+func (h *Handler) AddIndex(ctx context.Context, path, deployed string, contents *store.CompileContents, min, serviceWorker bool, send func(services.Message)) error {
+
+	storer := constor.New(ctx, h.Fileserver, send, config.ConcurrentStorageUploads)
+	defer storer.Close()
+
+	names := indexNames(path, min)
+	if contents.Index != "" {
+		names = []string{contents.Index}
+	}
+
+	loaderUrls := []string{cdn.LoaderUrl(path, deployed), cdn.LoaderUrl(path, contents.Main)}
+
+	for _, name := range names {
+		exists, err := h.Fileserver.Exists(ctx, config.Bucket[config.Index], name)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+		index, err := cdn.Read(ctx, h.Fileserver, config.Bucket[config.Index], name)
+		if err != nil {
+			return err
+		}
+
+		head := cdn.NewManifest(path, contents.Main, contents.Integrity, contents.Module, contents.Packages).Links()
+		if serviceWorker {
+			item, script, err := getServiceWorker(path, name, *contents)
+			if err != nil {
+				return err
+			}
+			storer.Add(item)
+			head += script
+		}
+		index = cdn.SetLoader(index, loaderUrls, cdn.LoaderUrl(path, contents.Main), contents.Integrity)
+		index = cdn.SetHead(index, head)
+
+		if contents.Index != "" {
+			contents.Index = cdn.Hash(index)
+			storer.Add(constor.Item{
+				Message:   "index",
+				Name:      contents.Index,
+				Contents:  index,
+				Bucket:    config.Bucket[config.Index],
+				Mime:      constor.MimeHtml,
+				Immutable: true,
+			})
+			continue
+		}
+		storer.Add(constor.Item{
+			Message:  "index",
+			Name:     name,
+			Contents: index,
+			Bucket:   config.Bucket[config.Index],
+			Mime:     constor.MimeHtml,
+		})
+	}
+
+	if err := storer.Wait(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// indexNames returns the names of the index pages the deployer creates in the index bucket for a
+// package. Packages on github.com also have an index page at the short path.
+func indexNames(path string, min bool) []string {
+	names := []string{path}
+	if short := strings.TrimPrefix(path, "github.com/"); short != path {
+		names = append(names, short)
+	}
+	if !min {
+		for i := range names {
+			names[i] += "$max"
+		}
+	}
+	return names
+}
+
+// AddAliases stores a page in the index bucket for each alias of the path (e.g. gl:foo/bar for
+// gitlab.com/foo/bar) that redirects to the index page.
+func (h *Handler) AddAliases(ctx context.Context, path string, send func(services.Message)) error {
+	storer := constor.New(ctx, h.Fileserver, send, config.ConcurrentStorageUploads)
+	defer storer.Close()
+	for _, name := range aliasNames(path) {
+		for _, suffix := range []string{"", "$max"} {
+			storer.Add(constor.Item{
+				Message:  "alias",
+				Name:     name + suffix,
+				Contents: []byte(fmt.Sprintf(aliasPage, html.EscapeString("/"+path+suffix))),
+				Bucket:   config.Bucket[config.Index],
+				Mime:     constor.MimeHtml,
+			})
+		}
+	}
+	return storer.Wait()
+}
+
+const aliasPage = `<html>
+	<head>
+		<meta charset="utf-8">
+		<meta http-equiv="refresh" content="0; url=%[1]s">
+	</head>
+	<body>
+		<a href="%[1]s">Redirecting...</a>
+	</body>
+</html>`
@@ -0,0 +1,67 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package jsgo
+
+import (
+	"context"
+
+	"github.com/dave/services"
+	"github.com/dave/services/constor"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/cdn"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/store"
+)
+
+// AddIntegrity calculates Subresource Integrity digests for every package file, and stores a loader that
+// checks them (see cdn.SecureLoader) under its own hash. The hash and digest of the new loader are stored
+// in contents, and AddIndex points the index pages at it.
+func (h *Handler) AddIntegrity(ctx context.Context, files *compileFiles, path string, contents *store.CompileContents, min bool, send func(services.Message)) error {
+
+	loader, err := files.read(ctx, cdn.LoaderName(path, contents.Main))
+	if err != nil {
+		return err
+	}
+
+	infos, err := files.packageInfos(ctx, contents.Packages)
+	if err != nil {
+		return err
+	}
+	packages := map[string]string{}
+	for i, p := range contents.Packages {
+		contents.Packages[i].Integrity = infos[i].integrity
+		packages[cdn.PackageUrl(p.Path, p.Hash)] = contents.Packages[i].Integrity
+	}
+
+	secure, err := cdn.SecureLoader(loader, packages)
+	if err != nil {
+		return err
+	}
+	contents.Main = cdn.Hash(secure)
+	contents.Integrity = cdn.Integrity(secure)
+
+	name := cdn.LoaderName(path, contents.Main)
+	files.add(name, secure)
+
+	var minified = " (un-minified)"
+	if min {
+		minified = " (minified)"
+	}
+
+	storer := constor.New(ctx, h.Fileserver, send, config.ConcurrentStorageUploads)
+	defer storer.Close()
+	storer.Add(constor.Item{
+		Message:   "loader" + minified,
+		Name:      name,
+		Contents:  secure,
+		Bucket:    config.Bucket[config.Pkg],
+		Mime:      constor.MimeJs,
+		Count:     true,
+		Immutable: true,
+		Send:      true,
+	})
+	return storer.Wait()
+}
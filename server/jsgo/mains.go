@@ -0,0 +1,91 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package jsgo
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/dave/services/getter/get"
+	"github.com/dave/services/session"
+)
+
+// getMains downloads the packages in paths and returns the import paths of the main packages to
+// compile. Paths ending in "/..." are patterns matching all the main packages below the root.
+func getMains(ctx context.Context, s *session.Session, getter *get.Getter, paths []string, insecure bool) ([]string, error) {
+	var mains []string
+	found := map[string]bool{}
+	add := func(path string) {
+		if found[path] {
+			return
+		}
+		found[path] = true
+		mains = append(mains, path)
+	}
+	for _, path := range paths {
+		if !strings.HasSuffix(path, "/...") {
+			if err := getter.Get(ctx, path, false, insecure, false); err != nil {
+				return nil, err
+			}
+			add(path)
+			continue
+		}
+		root := strings.TrimSuffix(path, "/...")
+		// The root of a pattern needn't be a package itself, so only fail if nothing was downloaded.
+		if err := getter.Get(ctx, root, false, insecure, true); err != nil {
+			if _, serr := s.GoPath().Stat(filepath.Join("/gopath", "src", root)); serr != nil {
+				return nil, err
+			}
+		}
+		matches, err := findMains(s, root)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no main packages match %s", path)
+		}
+		for _, match := range matches {
+			if err := getter.Get(ctx, match, false, insecure, false); err != nil {
+				return nil, err
+			}
+			add(match)
+		}
+	}
+	return mains, nil
+}
+
+// findMains walks the source below root and returns the import paths of the main packages. Like the go
+// tool, vendor and testdata directories and directories starting with "." or "_" are skipped.
+func findMains(s *session.Session, root string) ([]string, error) {
+	bctx := s.BuildContext(session.DefaultType, "")
+	var mains []string
+	var walk func(path string) error
+	walk = func(path string) error {
+		infos, err := s.GoPath().ReadDir(filepath.Join("/gopath", "src", path))
+		if err != nil {
+			return err
+		}
+		if p, err := bctx.Import(path, "", 0); err == nil && p.Name == "main" {
+			mains = append(mains, path)
+		}
+		for _, info := range infos {
+			name := info.Name()
+			if !info.IsDir() || name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+				continue
+			}
+			if err := walk(path + "/" + name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(root); err != nil {
+		return nil, err
+	}
+	return mains, nil
+}
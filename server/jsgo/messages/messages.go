@@ -7,21 +7,104 @@ package messages
 
 import (
 	"encoding/json"
+	"fmt"
 	"reflect"
 
 	"github.com/dave/services"
 	"github.com/gorilla/websocket"
 )
 
+// payloads are the messages sent by the client. Progress and data messages are only sent by the server
+// so are not needed here.
+var payloads = []interface{}{
+	Compile{},
+	Upload{},
+}
+
 type Compile struct {
-	Path string
+	Path           string   // Import path of the main package, or a pattern ending in "/..."
+	Paths          []string // More main packages (or patterns) to compile in the same job
+	Bundle         bool     // Also create a single JS file containing the prelude and all packages
+	Dce            bool     // Use dead code elimination when creating the bundle
+	SourceMaps     bool     // Store a source map for each package, and package JS that refers to it
+	SourcesContent bool     // Include the Go source in the source maps (if SourceMaps is set)
+	Verify         bool     // Compile a second time to check the output is deterministic
+	ServiceWorker  bool     // Create a service worker that caches the files so the app works offline
+	Modules        bool     // Also create an ES module for each package, and an ES module entry point
 }
 
 type Complete struct {
-	Path    string
-	Short   string
-	HashMin string
-	HashMax string
+	Path         string
+	Short        string
+	HashMin      string
+	HashMax      string
+	IntegrityMin string // Subresource Integrity digest of the minified loader
+	IntegrityMax string // Subresource Integrity digest of the un-minified loader
+	BundleMin    string // Hash of the minified bundle, if requested
+	BundleMax    string // Hash of the un-minified bundle, if requested
+	ModuleMin    string // Hash of the minified ES module entry point, if requested
+	ModuleMax    string // Hash of the un-minified ES module entry point, if requested
+	PackagesMin  []Package
+	PackagesMax  []Package
+	DiffMin      *Diff   // Changes since the previous compile of the minified JS
+	DiffMax      *Diff   // Changes since the previous compile of the un-minified JS
+	Verify       *Verify // Result of the reproducibility check, if requested
+	Private      bool    // Compiled with stored credentials or uploaded, so not published at the package path
+	IndexMin     string  // Hash of the minified index page, if Private
+	IndexMax     string  // Hash of the un-minified index page, if Private
+	Upload       string  // Content hash of the source, if uploaded
+}
+
+// Verifying is sent while the package is compiled a second time to check the output is deterministic.
+type Verifying struct {
+	Starting bool
+	Done     bool
+}
+
+// Verify is the result of compiling a package twice in separate sessions.
+type Verify struct {
+	Deterministic bool     // The loader and non-standard packages compiled the same both times
+	Main          bool     // The loader hash changed
+	Packages      []string // Packages with output that changed
+	Unverified    []string // Standard library packages, which are precompiled so aren't compiled again
+}
+
+// Diff compares the packages in a compile with the previous compile of the same path.
+type Diff struct {
+	Previous  bool // False if there's no previous compile to compare with
+	Added     []DiffPackage
+	Removed   []DiffPackage
+	Changed   []DiffPackage
+	New       int // Estimated bytes downloaded by a new visitor
+	Returning int // Estimated bytes downloaded by a returning visitor with the previous compile cached
+}
+
+// DiffPackage is a package that has been added, removed or changed since the previous compile.
+type DiffPackage struct {
+	Path         string
+	Size         int // Size of the compiled JS in bytes
+	PreviousSize int // Size of the previously compiled JS in bytes
+}
+
+// Upload is sent by the client to compile the source in an uploaded archive. The archive may contain a
+// GOPATH (packages below a "src" directory) or a module (a go.mod file). Dependencies that aren't in the
+// archive are downloaded. Complete is sent when it has been compiled. Uploads are keyed by the content
+// hash of the source, so the same source is only compiled once.
+type Upload struct {
+	Archive []byte   // Contents of the archive
+	Format  string   // "zip" or "tar.gz"
+	Main    string   // Import path of the main package. Defaults to the module path for modules.
+	Tags    []string // Build tags
+}
+
+// Package is a compiled package file in Complete.
+type Package struct {
+	Path      string
+	Hash      string
+	Standard  bool
+	Integrity string // Subresource Integrity digest
+	Size      int    // Size in bytes
+	Gzip      int    // Gzipped size in bytes
 }
 
 func Marshal(in services.Message) ([]byte, int, error) {
@@ -42,10 +125,27 @@ func Marshal(in services.Message) ([]byte, int, error) {
 func Unmarshal(in []byte) (services.Message, error) {
 	var m struct {
 		Type    string
-		Message Compile // the jsgo compile page only ever sends Compile messages
+		Message json.RawMessage
 	}
 	if err := json.Unmarshal(in, &m); err != nil {
 		return nil, err
 	}
-	return m.Message, nil
+	typ, ok := payloadTypes[m.Type]
+	if !ok {
+		return nil, fmt.Errorf("type not found: %s", m.Type)
+	}
+	pointer := reflect.New(typ)
+	if err := json.Unmarshal(m.Message, pointer.Interface()); err != nil {
+		return nil, err
+	}
+	return pointer.Elem().Interface(), nil
 }
+
+func init() {
+	for _, i := range payloads {
+		t := reflect.TypeOf(i)
+		payloadTypes[t.Name()] = t
+	}
+}
+
+var payloadTypes = make(map[string]reflect.Type)
@@ -0,0 +1,142 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package jsgo
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/dave/services"
+	"github.com/dave/services/builder"
+	"github.com/dave/services/constor"
+	"github.com/gopherjs/gopherjs/compiler"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/cdn"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/store"
+)
+
+// Modules stores every package as an ES module that imports the prelude and its dependencies, and an
+// entry point module (<path>.module.<hash>.mjs) that imports every package and starts the program. The
+// hashes of the package modules and the entry point are added to contents.
+//
+// The prelude declares the GopherJS run-time as globals, so the prelude module evaluates it in the
+// global scope. Package modules are named by the hash of the module (not the package JS), because the
+// import statements include the names of the dependencies.
+func (h *Handler) Modules(ctx context.Context, files *compileFiles, archives []*compiler.Archive, path string, contents *store.CompileContents, min bool, send func(services.Message)) error {
+
+	imports := getImports(archives)
+
+	packages, err := files.readPackages(ctx, contents.Packages)
+	if err != nil {
+		return err
+	}
+
+	var minified = " (un-minified)"
+	if min {
+		minified = " (minified)"
+	}
+
+	storer := constor.New(ctx, h.Fileserver, send, config.ConcurrentStorageUploads)
+	defer storer.Close()
+
+	add := func(message, name string, contents []byte) {
+		storer.Add(constor.Item{
+			Message:   message + minified,
+			Name:      name,
+			Contents:  contents,
+			Bucket:    config.Bucket[config.Pkg],
+			Mime:      constor.MimeJs,
+			Count:     true,
+			Immutable: true,
+			Send:      true,
+		})
+	}
+
+	// Packages are in dependency order, so the modules for the dependencies of a package are always
+	// named before the package.
+	names := map[string]string{}
+	entry := &bytes.Buffer{}
+	modules := make([]store.CompilePackage, len(contents.Packages))
+	for i, p := range contents.Packages {
+		buf := &bytes.Buffer{}
+		if p.Path == "prelude" {
+			js, err := json.Marshal(string(packages[i]))
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(buf, preludeModule, js)
+		} else {
+			fmt.Fprintf(buf, "import %q;\n", relativeModule(p.Path, names["prelude"]))
+			for _, imp := range imports[p.Path] {
+				if name, ok := names[imp]; ok {
+					fmt.Fprintf(buf, "import %q;\n", relativeModule(p.Path, name))
+				}
+			}
+			buf.Write(packages[i])
+			fmt.Fprintf(buf, "\n$load[%q]();\n", p.Path)
+		}
+		p.Module = fmt.Sprintf("%x", sha1.Sum(buf.Bytes()))
+		modules[i] = p
+		name := cdn.ModuleName(p.Path, p.Module)
+		names[p.Path] = name
+		add(p.Path+" module", name, buf.Bytes())
+		fmt.Fprintf(entry, "import %q;\n", relativeModule(path, name))
+	}
+	fmt.Fprintf(entry, entryModule, path)
+
+	hash := fmt.Sprintf("%x", sha1.Sum(entry.Bytes()))
+	add("entry module", cdn.EntryModuleName(path, hash), entry.Bytes())
+
+	if err := storer.Wait(); err != nil {
+		return err
+	}
+
+	contents.Packages = modules
+	contents.Module = hash
+	return nil
+}
+
+// getImports returns the (unvendored) imports of every package in the program.
+func getImports(archives []*compiler.Archive) map[string][]string {
+	imports := map[string][]string{}
+	for _, archive := range archives {
+		p := builder.UnvendorPath(archive.ImportPath)
+		for _, imp := range archive.Imports {
+			imports[p] = append(imports[p], builder.UnvendorPath(imp))
+		}
+	}
+	return imports
+}
+
+// relativeModule returns the import specifier for the module name, relative to the module for the
+// package at from. Modules are stored next to each other in the pkg bucket, so relative specifiers work
+// on pkg.jsgo.io and when self hosting.
+func relativeModule(from, name string) string {
+	depth := strings.Count(from, "/")
+	if depth == 0 {
+		return "./" + name
+	}
+	return strings.Repeat("../", depth) + name
+}
+
+const preludeModule = `var $g = typeof globalThis !== "undefined" ? globalThis : typeof self !== "undefined" ? self : window;
+if (!$g.$load) {
+	$g.$load = {};
+}
+(0, eval)(%s);
+`
+
+const entryModule = `var $mainPkg = $packages[%q];
+$synthesizeMethods();
+$packages["runtime"].$init();
+$go($mainPkg.$init, []);
+$flushConsole();
+`
@@ -25,24 +25,17 @@ func Page(w http.ResponseWriter, req *http.Request, database services.Database)
 	ctx, cancel := context.WithTimeout(req.Context(), config.PageTimeout)
 	defer cancel()
 
-	path := normalizePath(strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, "/"), "/"))
+	path := resolvePath(strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, "/"), "/"))
 
 	if path == "" {
 		http.Redirect(w, req, "https://github.com/sniperkit/snk.fork.dave-jsgo", http.StatusFound)
 		return
 	}
 
-	var found bool
-	var data store.CompileData
-	var err error
-	if config.LOCAL {
-		found = false
-	} else {
-		found, data, err = store.Package(ctx, database, path)
-		if err != nil {
-			http.Error(w, err.Error(), 500)
-			return
-		}
+	found, data, err := store.Package(ctx, database, path)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
 	}
 
 	type vars struct {
@@ -117,6 +110,29 @@ var compilePageTemplate = template.Must(template.New("main").Funcs(template.Func
 						<p class="lead" id="button-panel">
 							<a href="#" class="btn btn-lg btn-secondary" id="btn">Compile</a>
 						</p>
+						<p id="options-panel">
+							<small>
+								<input type="checkbox" id="bundle-checkbox"> <label for="bundle-checkbox" class="text-muted">Single file bundle</label>
+							</small>
+							<small>
+								<input type="checkbox" id="dce-checkbox"> <label for="dce-checkbox" class="text-muted">Dead code elimination</label>
+							</small>
+							<small>
+								<input type="checkbox" id="source-maps-checkbox"> <label for="source-maps-checkbox" class="text-muted">Source maps</label>
+							</small>
+							<small>
+								<input type="checkbox" id="sources-content-checkbox"> <label for="sources-content-checkbox" class="text-muted">Source in source maps</label>
+							</small>
+							<small>
+								<input type="checkbox" id="verify-checkbox"> <label for="verify-checkbox" class="text-muted">Verify reproducible</label>
+							</small>
+							<small>
+								<input type="checkbox" id="service-worker-checkbox"> <label for="service-worker-checkbox" class="text-muted">Offline (service worker)</label>
+							</small>
+							<small>
+								<input type="checkbox" id="modules-checkbox"> <label for="modules-checkbox" class="text-muted">ES modules</label>
+							</small>
+						</p>
 					</div>
 
 					<div id="complete-panel" style="display: none;">
@@ -125,6 +141,10 @@ var compilePageTemplate = template.Must(template.New("main").Funcs(template.Func
 								Complete!
 							</h1>
 
+							<p id="complete-main-holder" style="display: none;">
+								<select id="complete-main" class="form-control"></select>
+							</p>
+
 							<h3><small class="text-muted">Link</small></h3>
 							<p>
 								<a id="complete-link" href=""></a>
@@ -135,6 +155,51 @@ var compilePageTemplate = template.Must(template.New("main").Funcs(template.Func
 								<input id="complete-script" type="text" onclick="this.select()" class="form-control" />
 							</p>
 
+							<div id="complete-module-holder" style="display: none;">
+								<h3><small class="text-muted">ES module</small></h3>
+								<p>
+									<input id="complete-module" type="text" onclick="this.select()" class="form-control" />
+								</p>
+							</div>
+
+							<h3><small class="text-muted">Self hosting</small></h3>
+							<p>
+								<a id="complete-export" href="">Download zip</a>
+							</p>
+
+							<div id="complete-bundle-holder" style="display: none;">
+								<h3><small class="text-muted">Bundle JS</small></h3>
+								<p>
+									<input id="complete-bundle" type="text" onclick="this.select()" class="form-control" />
+								</p>
+							</div>
+
+							<div id="complete-verify-holder" style="display: none;">
+								<h3><small class="text-muted">Reproducibility</small></h3>
+								<p id="complete-verify"></p>
+							</div>
+
+							<h3><small class="text-muted">Size</small></h3>
+							<div id="complete-treemap" style="position: relative; height: 300px; margin-bottom: 1rem;"></div>
+							<table class="table table-dark table-sm">
+								<thead>
+									<tr>
+										<th scope="col" class="sort-header" data-sort="Path" style="cursor: pointer;">Package</th>
+										<th scope="col" class="sort-header" data-sort="Size" style="cursor: pointer;">Size</th>
+										<th scope="col" class="sort-header" data-sort="Gzip" style="cursor: pointer;">Gzip</th>
+									</tr>
+								</thead>
+								<tbody id="complete-size-table"></tbody>
+							</table>
+
+							<div id="complete-diff-holder" style="display: none;">
+								<h3><small class="text-muted">Cache impact</small></h3>
+								<p id="complete-diff-summary"></p>
+								<table class="table table-dark table-sm">
+									<tbody id="complete-diff-table"></tbody>
+								</table>
+							</div>
+
 							<p>
 								<small>
 									<input type="checkbox" id="minify-checkbox" checked> <label for="minify-checkbox" class="text-muted">Minify</label>
@@ -142,6 +207,9 @@ var compilePageTemplate = template.Must(template.New("main").Funcs(template.Func
 								<small id="short-url-checkbox-holder">
 									<input type="checkbox" id="short-url-checkbox" checked> <label for="short-url-checkbox" class="text-muted">Short URL</label>
 								</small>
+								<small>
+									<input type="checkbox" id="integrity-checkbox"> <label for="integrity-checkbox" class="text-muted">Script tag with integrity</label>
+								</small>
 							</p>
 							
 						</div>
@@ -166,6 +234,10 @@ var compilePageTemplate = template.Must(template.New("main").Funcs(template.Func
 									<th scope="row" class="w-25">Storing:</th>
 									<td class="w-75"><span id="storing-span"></span></td>
 								</tr>
+								<tr id="verifying-item" style="display: none;">
+									<th scope="row" class="w-25">Verifying:</th>
+									<td class="w-75"><span id="verifying-span"></span></td>
+								</tr>
 							</tbody>
 						</table>
 					</div>
@@ -181,27 +253,170 @@ var compilePageTemplate = template.Must(template.New("main").Funcs(template.Func
 		</a>
 	</body>
 	<script>
+		var finals = [];
 		var final = {};
 		var refresh = function() {
+			final = finals[document.getElementById("complete-main").selectedIndex] || {};
 			var minify = document.getElementById("minify-checkbox").checked;
 			var short = document.getElementById("short-url-checkbox").checked;
+			var integrity = document.getElementById("integrity-checkbox").checked;
 			var completeLink = document.getElementById("complete-link");
 			var completeScript = document.getElementById("complete-script");
 			var shortUrlCheckboxHolder = document.getElementById("short-url-checkbox-holder");
 			
-			shortUrlCheckboxHolder.style.display = (final.Short == final.Path) ? "none" : "";
-			completeLink.href = "{{ .IndexProtocol }}://{{ .IndexHost }}/" + (short ? final.Short : final.Path) + (minify ? "" : "$max");
-			completeLink.innerHTML = "{{ .IndexHost }}/" + (short ? final.Short : final.Path) + (minify ? "" : "$max");
+			shortUrlCheckboxHolder.style.display = (final.Private || final.Short == final.Path) ? "none" : "";
+			// Private packages aren't published at their path, so the index page is stored by hash.
+			var page = final.Private ? (minify ? final.IndexMin : final.IndexMax) : (short ? final.Short : final.Path) + (minify ? "" : "$max");
+			completeLink.href = "{{ .IndexProtocol }}://{{ .IndexHost }}/" + page;
+			completeLink.innerHTML = "{{ .IndexHost }}/" + page;
 			completeScript.value = "{{ .PkgProtocol }}://{{ .PkgHost }}/" + final.Path + "." + (minify ? final.HashMin : final.HashMax) + ".js"
+			if (integrity) {
+				completeScript.value = '<script src="' + completeScript.value + '" integrity="' + (minify ? final.IntegrityMin : final.IntegrityMax) + '" crossorigin="anonymous"><\/script>';
+			}
+
+			document.getElementById("complete-export").style.display = final.Private ? "none" : "";
+			document.getElementById("complete-export").href = "/_export/" + final.Path + (minify ? "" : "$max") + ".zip";
+
+			var bundle = minify ? final.BundleMin : final.BundleMax;
+			document.getElementById("complete-bundle-holder").style.display = bundle ? "" : "none";
+			document.getElementById("complete-bundle").value = bundle ? "{{ .PkgProtocol }}://{{ .PkgHost }}/" + final.Path + ".bundle." + bundle + ".js" : "";
+
+			var module = minify ? final.ModuleMin : final.ModuleMax;
+			document.getElementById("complete-module-holder").style.display = module ? "" : "none";
+			document.getElementById("complete-module").value = module ? "import \"{{ .PkgProtocol }}://{{ .PkgHost }}/" + final.Path + ".module." + module + ".mjs\";" : "";
+
+			renderSizes(minify ? final.PackagesMin : final.PackagesMax);
+			renderVerify(final.Verify);
+			renderDiff(minify ? final.DiffMin : final.DiffMax);
+		}
+		var kb = function(bytes) {
+			return (bytes / 1024).toFixed(1) + " KB";
+		}
+		var renderDiff = function(diff) {
+			document.getElementById("complete-diff-holder").style.display = (diff && diff.Previous) ? "" : "none";
+			var table = document.getElementById("complete-diff-table");
+			table.innerHTML = "";
+			if (!diff || !diff.Previous) {
+				return;
+			}
+			document.getElementById("complete-diff-summary").textContent = "New visitors download " + kb(diff.New) + ", returning visitors download " + kb(diff.Returning) + ".";
+			var add = function(change, packages) {
+				(packages || []).forEach(function(p) {
+					var row = table.insertRow();
+					row.insertCell().textContent = change;
+					row.insertCell().textContent = p.Path;
+					row.insertCell().textContent = change == "Added" ? kb(p.Size) : change == "Removed" ? "-" + kb(p.PreviousSize) : kb(p.PreviousSize) + " → " + kb(p.Size);
+				});
+			}
+			add("Added", diff.Added);
+			add("Changed", diff.Changed);
+			add("Removed", diff.Removed);
+		}
+		var renderVerify = function(verify) {
+			document.getElementById("complete-verify-holder").style.display = verify ? "" : "none";
+			if (!verify) {
+				return;
+			}
+			var text = "The output is deterministic.";
+			if (!verify.Deterministic) {
+				text = "The output changed when compiled again: " + (verify.Packages || []).concat(verify.Main ? ["loader"] : []).join(", ") + ".";
+			}
+			if (verify.Unverified && verify.Unverified.length) {
+				text += " The standard library is precompiled, so " + verify.Unverified.length + " standard library packages weren't verified.";
+			}
+			document.getElementById("complete-verify").textContent = text;
+		}
+		var sizeSort = {field: "Size", descending: true};
+		var renderSizes = function(packages) {
+			packages = (packages || []).slice();
+			packages.sort(function(a, b) {
+				var x = a[sizeSort.field], y = b[sizeSort.field];
+				var order = x < y ? -1 : x > y ? 1 : 0;
+				return sizeSort.descending ? -order : order;
+			});
+			var table = document.getElementById("complete-size-table");
+			table.innerHTML = "";
+			var total = {Size: 0, Gzip: 0};
+			packages.forEach(function(p) {
+				var row = table.insertRow();
+				row.insertCell().textContent = p.Path;
+				row.insertCell().textContent = kb(p.Size);
+				row.insertCell().textContent = kb(p.Gzip);
+				total.Size += p.Size;
+				total.Gzip += p.Gzip;
+			});
+			var row = table.insertRow();
+			row.insertCell().innerHTML = "<b>Total</b>";
+			row.insertCell().textContent = kb(total.Size);
+			row.insertCell().textContent = kb(total.Gzip);
+			renderTreemap(packages);
 		}
+		// renderTreemap lays out the packages in rectangles with areas proportional to the size, by
+		// recursively splitting the list in two halves of similar total size.
+		var renderTreemap = function(packages) {
+			var container = document.getElementById("complete-treemap");
+			container.innerHTML = "";
+			var items = packages.filter(function(p) { return p.Size > 0 }).sort(function(a, b) { return b.Size - a.Size });
+			var layout = function(items, x, y, w, h) {
+				if (items.length == 0) {
+					return;
+				}
+				if (items.length == 1) {
+					var p = items[0];
+					var div = document.createElement("div");
+					div.title = p.Path + ": " + kb(p.Size) + " (" + kb(p.Gzip) + " gzipped)";
+					div.textContent = (container.offsetWidth * w / 100 > 60 && container.offsetHeight * h / 100 > 16) ? p.Path : "";
+					div.style.cssText = "position: absolute; overflow: hidden; white-space: nowrap; font-size: 0.7rem; border: 1px solid #333; padding: 1px 3px;" +
+						"left: " + x + "%; top: " + y + "%; width: " + w + "%; height: " + h + "%;" +
+						"background-color: " + (p.Standard ? "#5a6268" : "#007bff") + ";";
+					container.appendChild(div);
+					return;
+				}
+				var total = 0;
+				items.forEach(function(p) { total += p.Size });
+				var split = 0, sum = 0;
+				while (split < items.length - 1 && sum + items[split].Size <= total / 2) {
+					sum += items[split].Size;
+					split++;
+				}
+				if (split == 0) {
+					sum = items[0].Size;
+					split = 1;
+				}
+				var ratio = sum / total;
+				var width = container.offsetWidth * w, height = container.offsetHeight * h;
+				if (width >= height) {
+					layout(items.slice(0, split), x, y, w * ratio, h);
+					layout(items.slice(split), x + w * ratio, y, w * (1 - ratio), h);
+				} else {
+					layout(items.slice(0, split), x, y, w, h * ratio);
+					layout(items.slice(split), x, y + h * ratio, w, h * (1 - ratio));
+				}
+			}
+			layout(items, 0, 0, 100, 100);
+		}
+		Array.prototype.forEach.call(document.getElementsByClassName("sort-header"), function(header) {
+			header.onclick = function() {
+				var field = header.getAttribute("data-sort");
+				sizeSort = {field: field, descending: sizeSort.field == field ? !sizeSort.descending : field != "Path"};
+				refresh();
+			}
+		});
+		document.getElementById("complete-main").onchange = refresh;
 		document.getElementById("minify-checkbox").onchange = refresh;
 		document.getElementById("short-url-checkbox").onchange = refresh;
+		document.getElementById("integrity-checkbox").onchange = refresh;
 		document.getElementById("btn").onclick = function(event) {
 			event.preventDefault();
-			var socket = new WebSocket("{{ .Scheme }}://{{ .Host }}/_jsgo/");
+			// The API token (if any) is in the fragment so it's not sent in requests or referrers, and it's
+			// passed to the server as a websocket subprotocol.
+			var token = new URLSearchParams(window.location.hash.slice(1)).get("token");
+			var url = "{{ .Scheme }}://{{ .Host }}/_jsgo/";
+			var socket = token ? new WebSocket(url, ["jsgo-token." + token]) : new WebSocket(url);
 
 			var headerPanel = document.getElementById("header-panel");
 			var buttonPanel = document.getElementById("button-panel");
+			var optionsPanel = document.getElementById("options-panel");
 			var progressPanel = document.getElementById("progress-panel");
 			var errorPanel = document.getElementById("error-panel");
 			var completePanel = document.getElementById("complete-panel");
@@ -214,10 +429,18 @@ var compilePageTemplate = template.Must(template.New("main").Funcs(template.Func
 				socket.send(JSON.stringify({
 					"Type": "Compile",
 					"Message": {
-						"Path": "{{ .Path }}"
+						"Path": "{{ .Path }}",
+						"Bundle": document.getElementById("bundle-checkbox").checked,
+						"Dce": document.getElementById("dce-checkbox").checked,
+						"SourceMaps": document.getElementById("source-maps-checkbox").checked,
+						"SourcesContent": document.getElementById("sources-content-checkbox").checked,
+						"Verify": document.getElementById("verify-checkbox").checked,
+						"ServiceWorker": document.getElementById("service-worker-checkbox").checked,
+						"Modules": document.getElementById("modules-checkbox").checked
 					}
 				}));
 				buttonPanel.style.display = "none";
+				optionsPanel.style.display = "none";
 				progressPanel.style.display = "";
 			};
 			socket.onmessage = function (e) {
@@ -227,6 +450,7 @@ var compilePageTemplate = template.Must(template.New("main").Funcs(template.Func
 				case "Downloading":
 				case "Compiling":
 				case "Storing":
+				case "Verifying":
 					if (done[payload.Type]) {
 						// Messages might arrive out of order... Once we get a "done", ignore 
 						// any more.
@@ -251,10 +475,16 @@ var compilePageTemplate = template.Must(template.New("main").Funcs(template.Func
 					}
 					break;
 				case "Complete":
+					// A Complete is sent for each main package, so the progress panel stays visible until
+					// the server closes the connection.
 					complete = true;
-					final = payload.Message;
+					done = {};
+					finals.push(payload.Message);
+					var option = document.createElement("option");
+					option.text = payload.Message.Path;
+					document.getElementById("complete-main").add(option);
+					document.getElementById("complete-main-holder").style.display = finals.length > 1 ? "" : "none";
 					completePanel.style.display = "";
-					progressPanel.style.display = "none";
 					headerPanel.style.display = "none";
 					refresh();
 					break;
@@ -268,6 +498,7 @@ var compilePageTemplate = template.Must(template.New("main").Funcs(template.Func
 					break;
 				}
 				socket.onclose = function() {
+					progressPanel.style.display = "none";
 					if (complete) {
 						return;
 					}
@@ -282,6 +513,8 @@ var compilePageTemplate = template.Must(template.New("main").Funcs(template.Func
 
 func normalizePath(path string) string {
 
+	path = expandAlias(path)
+
 	// We should normalize gist urls by removing the username part
 	if strings.HasPrefix(path, "gist.github.com/") {
 		matches := gistWithUsername.FindStringSubmatch(path)
@@ -0,0 +1,291 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package jsgo
+
+import (
+	"container/list"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/shurcooL/go/ctxhttp"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+)
+
+// aliases is the alias table from config, plus any extra aliases from the environment.
+var aliases = func() map[string]string {
+	a := map[string]string{}
+	for alias, host := range config.Aliases {
+		a[alias] = host
+	}
+	for _, pair := range strings.Split(os.Getenv(config.AliasesEnv), ",") {
+		if i := strings.Index(pair, "="); i > 0 {
+			a[strings.TrimSpace(pair[:i])] = strings.Trim(strings.TrimSpace(pair[i+1:]), "/")
+		}
+	}
+	return a
+}()
+
+// expandAlias expands a path that starts with an alias (e.g. gl:foo/bar) to the full import path. If
+// several aliases match, the longest is used.
+func expandAlias(path string) string {
+	var match string
+	for alias := range aliases {
+		if strings.HasPrefix(path, alias) && len(alias) > len(match) {
+			match = alias
+		}
+	}
+	if match == "" {
+		return path
+	}
+	return aliases[match] + "/" + strings.TrimPrefix(strings.TrimPrefix(path, match), "/")
+}
+
+// aliasNames returns the short paths for an import path, e.g. gl:foo/bar for gitlab.com/foo/bar. The
+// short github path is created by the deployer, so is not included.
+func aliasNames(path string) []string {
+	var names []string
+	for alias, host := range aliases {
+		if host == "github.com" {
+			continue
+		}
+		if strings.HasPrefix(path, host+"/") {
+			names = append(names, alias+strings.TrimPrefix(path, host+"/"))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolvePath normalizes the path, and uses the result of an earlier go-import meta tag lookup for
+// vanity import paths (see resolveVanity). It doesn't make any requests, so it's used by the HTTP
+// handlers (the compile page, exports and manifests), which all find packages the same way.
+func resolvePath(path string) string {
+	path = normalizePath(path)
+	if !vanityPath(path) {
+		return path
+	}
+	e, ok := vanity.get(path)
+	if !ok {
+		return path
+	}
+	return canonical(path, e.prefix, e.found)
+}
+
+// resolveVanity normalizes the path, and then looks up the go-import meta tag for vanity import paths.
+// The import path prefix in the meta tag is canonical, so it replaces the matching prefix of the path
+// (e.g. fixing the case). Known code hosts are not looked up, and lookups are cached. Only compile jobs
+// look up paths, so requests for pages never cause a request to another host.
+func resolveVanity(ctx context.Context, path string) string {
+	path = normalizePath(path)
+	if !vanityPath(path) {
+		return path
+	}
+	prefix, found := vanity.lookup(ctx, path)
+	return canonical(path, prefix, found)
+}
+
+func vanityPath(path string) bool {
+	return strings.Contains(path, "/") && !strings.HasSuffix(path, "/...") && !knownHost(path)
+}
+
+func canonical(path, prefix string, found bool) string {
+	if found && strings.HasPrefix(strings.ToLower(path+"/"), strings.ToLower(prefix+"/")) {
+		return prefix + path[len(prefix):]
+	}
+	return path
+}
+
+func knownHost(path string) bool {
+	host := path[:strings.Index(path, "/")]
+	if !strings.Contains(host, ".") {
+		return true // standard library or not a remote path
+	}
+	if host == "gist.github.com" {
+		return true
+	}
+	for _, h := range aliases {
+		if host == h {
+			return true
+		}
+	}
+	return false
+}
+
+var vanity = &vanityCache{entries: map[string]*list.Element{}, recent: list.New()}
+
+// vanityCache holds the results of recent lookups. The paths are requested by users, so the least
+// recently used entries are removed when there are more than config.VanityCacheSize.
+type vanityCache struct {
+	m       sync.Mutex
+	entries map[string]*list.Element
+	recent  *list.List // Entries, most recently used first
+}
+
+type vanityEntry struct {
+	path    string
+	prefix  string
+	found   bool
+	expires time.Time
+}
+
+// lookup gets the import path prefix from the go-import meta tag at path, in the same way as the go
+// tool. Failed lookups are cached too, so a host that's slow or refused isn't requested again.
+func (v *vanityCache) lookup(ctx context.Context, path string) (string, bool) {
+	if e, ok := v.get(path); ok {
+		return e.prefix, e.found
+	}
+
+	prefix, err := getImportPrefix(ctx, path)
+	e := &vanityEntry{path: path, prefix: prefix, found: err == nil && prefix != "", expires: time.Now().Add(config.VanityCacheTime)}
+	v.add(e)
+
+	return e.prefix, e.found
+}
+
+func (v *vanityCache) get(path string) (*vanityEntry, bool) {
+	v.m.Lock()
+	defer v.m.Unlock()
+	el, ok := v.entries[path]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*vanityEntry)
+	if !time.Now().Before(e.expires) {
+		v.recent.Remove(el)
+		delete(v.entries, path)
+		return nil, false
+	}
+	v.recent.MoveToFront(el)
+	return e, true
+}
+
+func (v *vanityCache) add(e *vanityEntry) {
+	v.m.Lock()
+	defer v.m.Unlock()
+	if el, ok := v.entries[e.path]; ok {
+		v.recent.Remove(el)
+	}
+	v.entries[e.path] = v.recent.PushFront(e)
+	for v.recent.Len() > config.VanityCacheSize {
+		el := v.recent.Back()
+		v.recent.Remove(el)
+		delete(v.entries, el.Value.(*vanityEntry).path)
+	}
+}
+
+// getImportPrefix gets the import path prefix from the go-import meta tag at path. The page is parsed
+// in the same way as the go tool: as lenient XML, stopping at the body.
+func getImportPrefix(ctx context.Context, path string) (string, error) {
+	resp, err := ctxhttp.Get(ctx, vanityClient, fmt.Sprintf("https://%s?go-get=1", path))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	d := xml.NewDecoder(resp.Body)
+	d.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		if strings.EqualFold(charset, "ascii") || strings.EqualFold(charset, "utf-8") {
+			return input, nil
+		}
+		return nil, fmt.Errorf("can't decode XML document using charset %q", charset)
+	}
+	d.Strict = false
+	d.AutoClose = xml.HTMLAutoClose
+	d.Entity = xml.HTMLEntity
+	for {
+		t, err := d.RawToken()
+		if err != nil {
+			if err == io.EOF {
+				return "", nil
+			}
+			return "", err
+		}
+		if e, ok := t.(xml.StartElement); ok && strings.EqualFold(e.Name.Local, "body") {
+			return "", nil
+		}
+		if e, ok := t.(xml.EndElement); ok && strings.EqualFold(e.Name.Local, "head") {
+			return "", nil
+		}
+		e, ok := t.(xml.StartElement)
+		if !ok || !strings.EqualFold(e.Name.Local, "meta") {
+			continue
+		}
+		if attrValue(e.Attr, "name") != "go-import" {
+			continue
+		}
+		fields := strings.Fields(attrValue(e.Attr, "content"))
+		if len(fields) == 3 && strings.HasPrefix(strings.ToLower(path+"/"), strings.ToLower(fields[0]+"/")) {
+			return fields[0], nil
+		}
+	}
+}
+
+// vanityClient only connects to public addresses. The paths are requested by users, so lookups must not
+// reach the internal network. The address is checked when connecting, so it applies to redirects and
+// can't be avoided with DNS that resolves differently the second time.
+var vanityClient = &http.Client{
+	Timeout: config.HttpTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: config.HttpTimeout,
+			Control: func(network, address string, c syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+					return fmt.Errorf("%s is not a public address", host)
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout: config.HttpTimeout,
+	},
+}
+
+// publicIP returns false for loopback, link-local, private, shared and unspecified addresses.
+func publicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, n := range privateNetworks {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+var privateNetworks = func() []*net.IPNet {
+	var networks []*net.IPNet
+	for _, cidr := range []string{"0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7"} {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, n)
+	}
+	return networks
+}()
+
+func attrValue(attrs []xml.Attr, name string) string {
+	for _, a := range attrs {
+		if strings.EqualFold(a.Name.Local, name) {
+			return a.Value
+		}
+	}
+	return ""
+}
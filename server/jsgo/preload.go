@@ -0,0 +1,72 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package jsgo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dave/services"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/cdn"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/store"
+)
+
+// Manifest serves the manifest of a compiled package at /_manifest/<path> (or <path>$max for the
+// un-minified version) as JSON, with a Link header that preloads the prelude and packages. With
+// ?format=module the Link header preloads the ES modules instead.
+func Manifest(w http.ResponseWriter, req *http.Request, database services.Database) {
+
+	ctx, cancel := context.WithTimeout(req.Context(), config.PageTimeout)
+	defer cancel()
+
+	path := strings.TrimPrefix(req.URL.Path, "/_manifest/")
+
+	min := true
+	if strings.HasSuffix(path, "$max") {
+		min = false
+		path = strings.TrimSuffix(path, "$max")
+	}
+
+	path = resolvePath(path)
+
+	found, data, err := store.Package(ctx, database, path)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	if !found {
+		http.Error(w, fmt.Sprintf("%s not found", path), 404)
+		return
+	}
+
+	contents := data.Max
+	if min {
+		contents = data.Min
+	}
+	manifest := cdn.NewManifest(path, contents.Main, contents.Integrity, contents.Module, contents.Packages)
+
+	link := manifest.LinkHeader()
+	if req.URL.Query().Get("format") == "module" {
+		if manifest.Module == "" {
+			http.Error(w, fmt.Sprintf("%s was compiled without ES modules", path), 404)
+			return
+		}
+		link = manifest.ModuleLinkHeader()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Link", link)
+	if err := json.NewEncoder(w).Encode(manifest); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+}
@@ -0,0 +1,102 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package jsgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+
+	"github.com/dave/services/constor"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/cdn"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/store"
+)
+
+// getServiceWorker returns a service worker for the index page called name (<name>.sw.js in the index
+// bucket), and the script that registers it from the page. The worker precaches the loader, prelude and
+// packages, serves them cache-first (they are immutable), and evicts files that are no longer in the
+// manifest when a new version is activated. The index page itself is served network-first so the app
+// works offline.
+func getServiceWorker(pkgpath, name string, contents store.CompileContents) (constor.Item, string, error) {
+
+	manifest := cdn.NewManifest(pkgpath, contents.Main, contents.Integrity, contents.Module, contents.Packages)
+	urls := []string{manifest.Loader.Url}
+	for _, f := range manifest.Packages {
+		urls = append(urls, f.Url)
+	}
+	b, err := json.Marshal(urls)
+	if err != nil {
+		return constor.Item{}, "", err
+	}
+
+	item := constor.Item{
+		Message:  "service worker",
+		Name:     name + ".sw.js",
+		Contents: []byte(fmt.Sprintf(serviceWorker, name, b)),
+		Bucket:   config.Bucket[config.Index],
+		Mime:     constor.MimeJs,
+	}
+
+	// The worker is next to the page. Pages of private packages are stored under the hash of the page,
+	// which includes this script, so the worker keeps the name the deployer gave the page.
+	return item, cdn.RegisterServiceWorker(path.Base(name) + ".sw.js"), nil
+}
+
+const serviceWorker = `"use strict";
+var CACHE = "jsgo:" + %q;
+var FILES = %s;
+var PAGE = self.registration.scope;
+
+self.addEventListener("install", function(event) {
+	event.waitUntil(caches.open(CACHE).then(function(cache) {
+		return cache.addAll(FILES.map(function(url) {
+			return new Request(url, {mode: "cors"});
+		}).concat([PAGE]));
+	}).then(function() {
+		return self.skipWaiting();
+	}));
+});
+
+self.addEventListener("activate", function(event) {
+	event.waitUntil(caches.open(CACHE).then(function(cache) {
+		return cache.keys().then(function(requests) {
+			return Promise.all(requests.filter(function(request) {
+				return request.url !== PAGE && FILES.indexOf(request.url) === -1;
+			}).map(function(request) {
+				return cache.delete(request);
+			}));
+		});
+	}).then(function() {
+		return self.clients.claim();
+	}));
+});
+
+self.addEventListener("fetch", function(event) {
+	var url = event.request.url;
+	if (url === PAGE) {
+		// network first for the page, so updates are picked up
+		event.respondWith(fetch(event.request).then(function(response) {
+			var copy = response.clone();
+			caches.open(CACHE).then(function(cache) {
+				cache.put(event.request, copy);
+			});
+			return response;
+		}).catch(function() {
+			return caches.match(event.request);
+		}));
+		return;
+	}
+	if (FILES.indexOf(url) === -1) {
+		return;
+	}
+	// cache first for the loader and packages, which are immutable
+	event.respondWith(caches.match(event.request).then(function(response) {
+		return response || fetch(event.request);
+	}));
+});
+`
@@ -0,0 +1,25 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package jsgo
+
+import (
+	"context"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/store"
+)
+
+// AddSizes stores the size and gzipped size of every package file (including the prelude) in contents.
+func (h *Handler) AddSizes(ctx context.Context, files *compileFiles, contents *store.CompileContents) error {
+	infos, err := files.packageInfos(ctx, contents.Packages)
+	if err != nil {
+		return err
+	}
+	for i := range contents.Packages {
+		contents.Packages[i].Size = infos[i].size
+		contents.Packages[i].Gzip = infos[i].gzip
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package jsgo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/build"
+	"go/token"
+	"io/ioutil"
+	pathpkg "path"
+	"path/filepath"
+
+	"github.com/dave/services"
+	"github.com/dave/services/builder"
+	"github.com/dave/services/constor"
+	"github.com/dave/services/session"
+	gbuild "github.com/gopherjs/gopherjs/build"
+	"github.com/gopherjs/gopherjs/compiler"
+	"github.com/neelance/sourcemap"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/cdn"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/store"
+)
+
+// SourceMaps creates a source map for each non-standard package in a compile, and stores it in the
+// pkg bucket next to the package file as <path>.<hash>.js.map. If sourcesContent is true, a second map
+// that includes the Go source is stored as <path>.<hash>.src.js.map.
+//
+// The files the deployer stored are named by hash, so they aren't changed. Instead a copy of each
+// package file with a sourceMappingURL comment that refers to the map is stored under its own hash, and
+// a loader that loads the copies is added to files. The hashes in contents are updated, so the later
+// steps (sizes, integrity, index pages, bundles and modules) use the copies.
+func (h *Handler) SourceMaps(ctx context.Context, s *session.Session, files *compileFiles, archives []*compiler.Archive, path string, contents *store.CompileContents, min, sourcesContent bool, send func(services.Message)) error {
+
+	byPath := map[string]*compiler.Archive{}
+	for _, archive := range archives {
+		byPath[builder.UnvendorPath(archive.ImportPath)] = archive
+	}
+
+	loader, err := files.read(ctx, cdn.LoaderName(path, contents.Main))
+	if err != nil {
+		return err
+	}
+
+	bctx := s.BuildContext(session.DefaultType, "")
+
+	var minified = " (un-minified)"
+	if min {
+		minified = " (minified)"
+	}
+
+	storer := constor.New(ctx, h.Fileserver, send, config.ConcurrentStorageUploads)
+	defer storer.Close()
+
+	add := func(message, name, mime string, contents []byte) {
+		storer.Add(constor.Item{
+			Message:   message + minified,
+			Name:      name,
+			Contents:  contents,
+			Bucket:    config.Bucket[config.Pkg],
+			Mime:      mime,
+			Count:     true,
+			Immutable: true,
+			Send:      true,
+		})
+	}
+
+	for i, p := range contents.Packages {
+		if p.Standard {
+			continue
+		}
+		archive, ok := byPath[p.Path]
+		if !ok {
+			return fmt.Errorf("can't find archive for %s", p.Path)
+		}
+		js, err := files.read(ctx, cdn.PackageName(p.Path, p.Hash))
+		if err != nil {
+			return err
+		}
+
+		debug := append(append([]byte(nil), js...), fmt.Sprintf("\n//# sourceMappingURL=%s\n", pathpkg.Base(cdn.SourceMapName(p.Path, p.Hash, sourcesContent)))...)
+		hash := cdn.Hash(debug)
+
+		m, err := getSourceMap(archive, js, bctx, cdn.PackageName(p.Path, hash), min)
+		if err != nil {
+			return err
+		}
+		add(p.Path+" source map", cdn.SourceMapName(p.Path, p.Hash, false), constor.MimeJson, m)
+		if sourcesContent {
+			src, err := addSourcesContent(m, bctx)
+			if err != nil {
+				return err
+			}
+			add(p.Path+" source map with source", cdn.SourceMapName(p.Path, p.Hash, true), constor.MimeJson, src)
+		}
+		add(p.Path+" with source map", cdn.PackageName(p.Path, hash), constor.MimeJs, debug)
+		files.add(cdn.PackageName(p.Path, hash), debug)
+
+		// The hash of each package is in the loader, and can't occur by chance.
+		if !bytes.Contains(loader, []byte(p.Hash)) {
+			return fmt.Errorf("can't find %s in loader", p.Path)
+		}
+		loader = bytes.Replace(loader, []byte(p.Hash), []byte(hash), -1)
+		contents.Packages[i].Hash = hash
+	}
+
+	// The loader isn't stored: AddIntegrity stores the loader that checks the digests.
+	contents.Main = cdn.Hash(loader)
+	files.add(cdn.LoaderName(path, contents.Main), loader)
+
+	if err := storer.Wait(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// buildArchives builds the program with the main package at path, and returns the archive of each
+// package. The deployer doesn't return the archives it built, so this is done once per deploy for the
+// source maps and modules.
+func buildArchives(ctx context.Context, s *session.Session, path string, min bool) ([]*compiler.Archive, error) {
+	b := builder.New(s, &builder.Options{Unvendor: true, Initializer: true, Minify: min})
+	if _, _, err := b.BuildImportPath(ctx, path); err != nil {
+		return nil, err
+	}
+	var archives []*compiler.Archive
+	for _, archive := range b.Archives {
+		archives = append(archives, archive)
+	}
+	return archives, nil
+}
+
+// getSourceMap generates the JS for the archive with a source map filter. The generated JS is found
+// in the package file stored by the deployer (js), and the mappings are offset by its position so
+// they match the stored file.
+func getSourceMap(archive *compiler.Archive, js []byte, bctx *build.Context, name string, min bool) ([]byte, error) {
+
+	type mapping struct {
+		line, column int
+		pos          token.Position
+	}
+	var mappings []mapping
+
+	buf := &bytes.Buffer{}
+	filter := &compiler.SourceMapFilter{Writer: buf}
+	filter.MappingCallback = func(line, column int, pos token.Position) {
+		mappings = append(mappings, mapping{line, column, pos})
+	}
+
+	selection := make(map[*compiler.Decl]struct{})
+	for _, d := range archive.Declarations {
+		selection[d] = struct{}{}
+	}
+	if err := compiler.WritePkgCode(archive, selection, min, filter); err != nil {
+		return nil, err
+	}
+
+	offset := bytes.Index(js, buf.Bytes())
+	if offset == -1 {
+		return nil, fmt.Errorf("generated code for %s doesn't match stored file", archive.ImportPath)
+	}
+	prefix := js[:offset]
+	lines := bytes.Count(prefix, []byte("\n"))
+	columns := len(prefix) - (bytes.LastIndex(prefix, []byte("\n")) + 1)
+
+	m := &sourcemap.Map{File: name}
+	callback := gbuild.NewMappingCallback(m, bctx.GOROOT, bctx.GOPATH, false)
+	for _, mp := range mappings {
+		column := mp.column
+		if mp.line == 1 {
+			column += columns
+		}
+		callback(mp.line+lines, column, mp.pos)
+	}
+
+	out := &bytes.Buffer{}
+	if err := m.WriteTo(out); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// addSourcesContent adds the contents of each source file to the sourcesContent field of the map.
+func addSourcesContent(b []byte, bctx *build.Context) ([]byte, error) {
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	sources, _ := m["sources"].([]interface{})
+	var contents []interface{}
+	for _, source := range sources {
+		name, _ := source.(string)
+		var found interface{}
+		for _, root := range []string{bctx.GOPATH, bctx.GOROOT} {
+			f, err := bctx.OpenFile(filepath.Join(root, "src", name))
+			if err != nil {
+				continue
+			}
+			b, err := ioutil.ReadAll(f)
+			f.Close()
+			if err != nil {
+				return nil, err
+			}
+			found = string(b)
+			break
+		}
+		contents = append(contents, found)
+	}
+	m["sourcesContent"] = contents
+	return json.Marshal(m)
+}
@@ -0,0 +1,386 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package jsgo
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dave/services"
+	"github.com/dave/services/deployer"
+	"github.com/dave/services/getter/get"
+	"github.com/dave/services/getter/gettermsg"
+	"github.com/dave/services/session"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/assets"
+	"github.com/sniperkit/snk.fork.dave-jsgo/assets/std"
+	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/cdn"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/jsgo/messages"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/serverconfig"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/store"
+)
+
+func (h *Handler) Upload(ctx context.Context, info messages.Upload, req *http.Request, send func(services.Message), receive chan services.Message) error {
+
+	if len(info.Archive) > config.MaxUploadSize {
+		return errors.New("archive is too large")
+	}
+
+	files, err := readArchive(info.Archive, info.Format)
+	if err != nil {
+		return err
+	}
+
+	source, module, err := getSource(files)
+	if err != nil {
+		return err
+	}
+
+	main := info.Main
+	if main == "" {
+		main = module
+	}
+	if main == "" {
+		return errors.New("main package must be specified")
+	}
+	if source[main] == nil {
+		return fmt.Errorf("can't find main package %s in archive", main)
+	}
+
+	hash := sourceHash(source, main, info.Tags)
+
+	// If the same source has been compiled before, the stored output is still valid.
+	found, data, err := store.Upload(ctx, h.Database, hash)
+	if err != nil {
+		return err
+	}
+	if found {
+		send(getUploadComplete(data))
+		return nil
+	}
+
+	s := session.New(info.Tags, assets.Assets, assets.Archives, h.Fileserver, config.ValidExtensions)
+
+	if err := s.SetSource(source); err != nil {
+		return err
+	}
+
+	// Send a message to the client that downloading step has started.
+	send(gettermsg.Downloading{Starting: true})
+
+	gitcache, _, err := h.Credentials.Cache(ctx, req, h.Cache)
+	if err != nil {
+		return err
+	}
+
+	gitreq := gitcache.NewRequest(false)
+	if err := gitreq.InitialiseFromHints(ctx, main); err != nil {
+		return err
+	}
+
+	// set insecure = true in local mode or it will fail if git repo has git protocol
+	insecure := config.LOCAL
+
+	// Start the download process - packages in the archive are used, and the remaining dependencies are
+	// downloaded just like the "go get" command.
+	if err := get.New(s, send, gitreq).Get(ctx, main, false, insecure, false); err != nil {
+		return err
+	}
+
+	if err := gitreq.Close(ctx); err != nil {
+		return err
+	}
+
+	// Send a message to the client that downloading step has finished.
+	send(gettermsg.Downloading{Done: true})
+
+	// Start the compile process - this compiles to JS and sends the files to a GCS bucket. The import
+	// path of uploaded source isn't public, so the index pages are named by hash.
+	output, err := deployer.New(s, send, std.Index, std.Prelude, serverconfig.DeployerConfig).Deploy(ctx, main, deployer.HashIndex, map[bool]bool{true: true, false: true})
+	if err != nil {
+		return err
+	}
+
+	// The loader checks the digest of each package, and the index page checks the digest of the loader.
+	contents := map[bool]store.DeployContents{}
+	for _, min := range []bool{true, false} {
+		c := getUploadContents(output[min], min)
+		if err := cdn.SecureDeploy(ctx, h.Fileserver, send, main, &c); err != nil {
+			return err
+		}
+		contents[min] = c
+	}
+
+	data = store.UploadData{
+		Time: time.Now(),
+		Hash: hash,
+		Main: main,
+		Tags: info.Tags,
+		Min:  contents[true],
+		Max:  contents[false],
+		Ip:   req.Header.Get("X-Forwarded-For"),
+	}
+	if err := store.StoreUpload(ctx, h.Database, data); err != nil {
+		return err
+	}
+
+	// Send a message to the client that the process has successfully finished
+	send(getUploadComplete(data))
+
+	return nil
+}
+
+// getUploadComplete returns the Complete message for an upload. The import path of uploaded source
+// isn't public, so it's Private like a compile with stored credentials.
+func getUploadComplete(data store.UploadData) messages.Complete {
+	return messages.Complete{
+		Path:         data.Main,
+		Short:        strings.TrimPrefix(data.Main, "github.com/"),
+		HashMin:      data.Min.Main,
+		HashMax:      data.Max.Main,
+		IntegrityMin: data.Min.Integrity,
+		IntegrityMax: data.Max.Integrity,
+		PackagesMin:  getCompletePackages(store.CompileContents{Packages: data.Min.Packages}),
+		PackagesMax:  getCompletePackages(store.CompileContents{Packages: data.Max.Packages}),
+		Private:      true,
+		IndexMin:     data.Min.Index,
+		IndexMax:     data.Max.Index,
+		Upload:       data.Hash,
+	}
+}
+
+func getUploadContents(c *deployer.DeployOutput, min bool) store.DeployContents {
+	val := store.DeployContents{}
+	val.Main = fmt.Sprintf("%x", c.MainHash)
+	val.Index = fmt.Sprintf("%x", c.IndexHash)
+	preludeHash := std.Prelude[min]
+	val.Packages = []store.CompilePackage{
+		{
+			Path:     "prelude",
+			Hash:     preludeHash,
+			Standard: true,
+		},
+	}
+	for _, p := range c.Packages {
+		val.Packages = append(val.Packages, store.CompilePackage{
+			Path:     p.Path,
+			Hash:     fmt.Sprintf("%x", p.Hash),
+			Standard: p.Standard,
+		})
+	}
+	return val
+}
+
+// readArchive returns the contents of the files in a zip or tar.gz archive.
+func readArchive(archive []byte, format string) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	var total int64
+	add := func(name string, r io.Reader) error {
+		clean := path.Clean(name)
+		if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return fmt.Errorf("invalid file name %q", name)
+		}
+		name = clean
+		b, err := ioutil.ReadAll(io.LimitReader(r, config.MaxUploadSize-total+1))
+		if err != nil {
+			return err
+		}
+		total += int64(len(b))
+		if total > config.MaxUploadSize {
+			return errors.New("extracted files are too large")
+		}
+		files[name] = b
+		return nil
+	}
+	switch format {
+	case "zip":
+		zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			r, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			err = add(f.Name, r)
+			r.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+	case "tar.gz":
+		gr, err := gzip.NewReader(bytes.NewReader(archive))
+		if err != nil {
+			return nil, err
+		}
+		tr := tar.NewReader(gr)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+				continue
+			}
+			if err := add(hdr.Name, tr); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported archive format %q", format)
+	}
+	return files, nil
+}
+
+// getSource converts the files in an archive to source packages: map[<package>]map[<filename>]<contents>.
+// If the archive contains a go.mod file, the module path is returned and packages are placed relative to
+// it (packages in the module vendor directory keep their own path). Otherwise packages must be below a
+// "src" directory, either at the root of the archive or in a single top level directory. Archives with
+// more than one candidate root are rejected.
+func getSource(files map[string][]byte) (source map[string]map[string]string, module string, err error) {
+
+	var root string
+	var modfile, ambiguous string
+	for name := range files {
+		if path.Base(name) != "go.mod" {
+			continue
+		}
+		if modfile == "" || strings.Count(name, "/") < strings.Count(modfile, "/") {
+			modfile, ambiguous = name, ""
+		} else if strings.Count(name, "/") == strings.Count(modfile, "/") {
+			ambiguous = name
+		}
+	}
+	if ambiguous != "" {
+		names := []string{modfile, ambiguous}
+		sort.Strings(names)
+		return nil, "", fmt.Errorf("archive contains more than one top level go.mod file: %s", strings.Join(names, ", "))
+	}
+	if modfile != "" {
+		for _, line := range strings.Split(string(files[modfile]), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 2 && fields[0] == "module" {
+				module = strings.Trim(fields[1], `"`)
+				break
+			}
+		}
+		if module == "" {
+			return nil, "", fmt.Errorf("no module path found in %s", modfile)
+		}
+		if dir := path.Dir(modfile); dir != "." {
+			root = dir + "/"
+		}
+	} else {
+		roots := map[string]bool{}
+		for name := range files {
+			parts := strings.Split(name, "/")
+			if len(parts) > 1 && parts[0] == "src" {
+				roots["src/"] = true
+			}
+			if len(parts) > 2 && parts[1] == "src" {
+				roots[parts[0]+"/src/"] = true
+			}
+		}
+		var sorted []string
+		for r := range roots {
+			sorted = append(sorted, r)
+		}
+		sort.Strings(sorted)
+		switch len(sorted) {
+		case 0:
+			return nil, "", errors.New("archive must contain a go.mod file or a src directory")
+		case 1:
+			root = sorted[0]
+		default:
+			return nil, "", fmt.Errorf("archive contains more than one src directory: %s", strings.Join(sorted, ", "))
+		}
+	}
+
+	source = map[string]map[string]string{}
+	for name, contents := range files {
+		if !strings.HasPrefix(name, root) || !validExtension(name) {
+			continue
+		}
+		rel := strings.TrimPrefix(name, root)
+		dir, file := path.Split(rel)
+		dir = strings.TrimSuffix(dir, "/")
+		var pkg string
+		switch {
+		case module == "":
+			pkg = dir
+		case dir == "vendor" || strings.HasPrefix(dir, "vendor/"):
+			pkg = strings.TrimPrefix(strings.TrimPrefix(dir, "vendor"), "/")
+		case dir == "":
+			pkg = module
+		default:
+			pkg = module + "/" + dir
+		}
+		if pkg == "" {
+			continue
+		}
+		if source[pkg] == nil {
+			source[pkg] = map[string]string{}
+		}
+		source[pkg][file] = string(contents)
+	}
+	return source, module, nil
+}
+
+func validExtension(name string) bool {
+	for _, ext := range config.ValidExtensions {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// sourceHash is the content hash of the source, main package and build tags.
+func sourceHash(source map[string]map[string]string, main string, tags []string) string {
+	sha := sha1.New()
+	fmt.Fprintf(sha, "main %q\n", main)
+	sorted := append([]string(nil), tags...)
+	sort.Strings(sorted)
+	fmt.Fprintf(sha, "tags %q\n", sorted)
+	var packages []string
+	for pkg := range source {
+		packages = append(packages, pkg)
+	}
+	sort.Strings(packages)
+	for _, pkg := range packages {
+		var names []string
+		for name := range source[pkg] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(sha, "%q %q %d\n", pkg, name, len(source[pkg][name]))
+			io.WriteString(sha, source[pkg][name])
+		}
+	}
+	return fmt.Sprintf("%x", sha.Sum(nil))
+}
@@ -0,0 +1,103 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package jsgo
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+
+	"github.com/dave/services"
+	"github.com/dave/services/deployer"
+	"github.com/dave/services/fsutil"
+	"github.com/dave/services/session"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/assets"
+	"github.com/sniperkit/snk.fork.dave-jsgo/assets/std"
+	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/jsgo/messages"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/serverconfig"
+)
+
+// Verify compiles the package a second time in a separate session from the same source, and compares
+// the package hashes and the loader hash with the first compile. Packages with output that isn't
+// deterministic would break content-addressed URLs, so they are reported in the result. The standard
+// library is precompiled by initialise (assets.Archives and std.Index), so both compiles use the same
+// standard library output: it isn't verified, and its packages are listed in Unverified.
+func (h *Handler) Verify(ctx context.Context, original *session.Session, path string, output map[bool]*deployer.DeployOutput, send func(services.Message)) (*messages.Verify, error) {
+
+	send(messages.Verifying{Starting: true})
+
+	s := session.New(nil, assets.Assets, assets.Archives, discardFileserver{h.Fileserver}, config.ValidExtensions)
+	if err := fsutil.Copy(s.GoPath(), "/gopath", original.GoPath(), "/gopath"); err != nil {
+		return nil, err
+	}
+
+	// Progress messages from the second compile would confuse the client.
+	discard := func(services.Message) {}
+	second, err := deployer.New(s, discard, std.Index, std.Prelude, serverconfig.DeployerConfig).Deploy(ctx, path, deployer.PathIndex, map[bool]bool{true: true, false: true})
+	if err != nil {
+		return nil, err
+	}
+
+	verify := &messages.Verify{Deterministic: true}
+	changed := map[string]bool{}
+	unverified := map[string]bool{}
+	for _, min := range []bool{true, false} {
+		if fmt.Sprintf("%x", output[min].MainHash) != fmt.Sprintf("%x", second[min].MainHash) {
+			verify.Main = true
+			verify.Deterministic = false
+		}
+		hashes := map[string]string{}
+		for _, p := range output[min].Packages {
+			if p.Standard {
+				unverified[p.Path] = true
+				continue
+			}
+			hashes[p.Path] = fmt.Sprintf("%x", p.Hash)
+		}
+		for _, p := range second[min].Packages {
+			if p.Standard {
+				continue
+			}
+			if hash, ok := hashes[p.Path]; !ok || hash != fmt.Sprintf("%x", p.Hash) {
+				changed[p.Path] = true
+				verify.Deterministic = false
+			}
+		}
+	}
+	for path := range changed {
+		verify.Packages = append(verify.Packages, path)
+	}
+	sort.Strings(verify.Packages)
+	for path := range unverified {
+		verify.Unverified = append(verify.Unverified, path)
+	}
+	sort.Strings(verify.Unverified)
+
+	send(messages.Verifying{Done: true})
+
+	return verify, nil
+}
+
+// discardFileserver is used for the second compile when verifying. Every file is reported as already
+// stored, and writes are discarded, so nothing from the second compile is stored.
+type discardFileserver struct {
+	services.Fileserver
+}
+
+func (discardFileserver) Exists(ctx context.Context, bucket, name string) (bool, error) {
+	return true, nil
+}
+
+func (discardFileserver) Write(ctx context.Context, bucket, name string, reader io.Reader, overwrite bool, mimeType, cacheControl string) (saved bool, err error) {
+	if _, err := io.Copy(ioutil.Discard, reader); err != nil {
+		return false, err
+	}
+	return false, nil
+}
@@ -20,7 +20,9 @@ import (
 	"github.com/sniperkit/snk.fork.dave-jsgo/assets"
 	"github.com/sniperkit/snk.fork.dave-jsgo/assets/std"
 	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/cdn"
 	"github.com/sniperkit/snk.fork.dave-jsgo/server/play/messages"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/serverconfig"
 	"github.com/sniperkit/snk.fork.dave-jsgo/server/store"
 )
 
@@ -39,7 +41,12 @@ func (h *Handler) Deploy(ctx context.Context, info messages.Deploy, req *http.Re
 	// Send a message to the client that downloading step has started.
 	send(gettermsg.Downloading{Starting: true})
 
-	gitreq := h.Cache.NewRequest(false)
+	gitcache, _, err := h.Credentials.Cache(ctx, req, h.Cache)
+	if err != nil {
+		return err
+	}
+
+	gitreq := gitcache.NewRequest(false)
 	if info.Main == "main" {
 		// Using package path "main" as a hint isn't useful... Instead use the imports.
 		// TODO: ignore standard library packages in this list.
@@ -68,30 +75,36 @@ func (h *Handler) Deploy(ctx context.Context, info messages.Deploy, req *http.Re
 	send(gettermsg.Downloading{Done: true})
 
 	// Start the compile process - this compiles to JS and sends the files to a GCS bucket.
-	output, err := deployer.New(s, send, std.Index, std.Prelude, config.DeployerConfig).Deploy(ctx, info.Main, deployer.HashIndex, map[bool]bool{true: true, false: false})
+	min := !info.NoMinify
+	output, err := deployer.New(s, send, std.Index, std.Prelude, serverconfig.DeployerConfig).Deploy(ctx, info.Main, deployer.HashIndex, map[bool]bool{min: true, !min: false})
 	if err != nil {
 		return err
 	}
 
-	if err := h.storeDeploy(ctx, send, true, req, output[true]); err != nil {
+	// The loader checks the digest of each package, and the index page checks the digest of the loader.
+	contents := getDeployContents(output[min], min)
+	if err := cdn.SecureDeploy(ctx, h.Fileserver, send, "", &contents); err != nil {
+		return err
+	}
+
+	if err := h.storeDeploy(ctx, min, req, contents); err != nil {
 		return err
 	}
 
 	// Send a message to the client that the process has successfully finished
-	// TODO: make minify configurable
 	send(messages.DeployComplete{
-		Main:  fmt.Sprintf("%x", output[true].MainHash),
-		Index: fmt.Sprintf("%x", output[true].IndexHash),
+		Main:  contents.Main,
+		Index: contents.Index,
 	})
 
 	return nil
 }
 
-func (h *Handler) storeDeploy(ctx context.Context, send func(services.Message), min bool, req *http.Request, output *deployer.DeployOutput) error {
+func (h *Handler) storeDeploy(ctx context.Context, min bool, req *http.Request, contents store.DeployContents) error {
 	data := store.DeployData{
 		Time:     time.Now(),
-		Contents: getDeployContents(output, min),
-		Minify:   min, // TODO: make this configurable
+		Contents: contents,
+		Minify:   min,
 		Ip:       req.Header.Get("X-Forwarded-For"),
 	}
 	if err := store.StoreDeploy(ctx, h.Database, data); err != nil {
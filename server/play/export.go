@@ -0,0 +1,50 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package play
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dave/services"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/cdn"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/store"
+)
+
+// Export serves a zip archive of a deploy at /_export/<index>.zip, where <index> is the hash of the
+// index page returned in DeployComplete.
+func Export(w http.ResponseWriter, req *http.Request, database services.Database, fileserver services.Fileserver) {
+
+	ctx, cancel := context.WithTimeout(req.Context(), config.ExportTimeout)
+	defer cancel()
+
+	index := strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, "/_export/"), ".zip")
+
+	found, data, err := store.Deploy(ctx, database, index)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	if !found {
+		http.Error(w, fmt.Sprintf("deploy %s not found", index), 404)
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	if err := cdn.Export(ctx, fileserver, buf, index, cdn.LoaderName("", data.Contents.Main), data.Contents.Packages); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", index+".zip"))
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,443 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package play
+
+import (
+	"bytes"
+	"context"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dave/services"
+	"github.com/dave/services/session"
+	"golang.org/x/tools/go/ast/astutil"
+	"gopkg.in/src-d/go-billy.v4"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/assets"
+	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/play/messages"
+)
+
+func (h *Handler) Format(ctx context.Context, info messages.Format, req *http.Request, send func(message services.Message), receive chan services.Message) error {
+
+	if !info.Imports {
+		send(formatSource(info.Source, nil))
+		return nil
+	}
+
+	std, err := getStdIndex()
+	if err != nil {
+		return err
+	}
+
+	// Missing imports are resolved against the standard library, the source packages, and the
+	// dependencies already downloaded by the editor. Nothing is downloaded, so formatting is fast and
+	// works while the source doesn't parse.
+	s := session.New(info.Tags, assets.Assets, assets.Archives, h.Fileserver, config.ValidExtensions)
+	if err := s.SetSource(info.Source); err != nil {
+		return err
+	}
+	source, err := newImportIndex(s.GoPath(), filepath.Join("gopath", "src"), nil)
+	if err != nil {
+		return err
+	}
+
+	var complete messages.FormatComplete
+	if err := h.Editor.Dependencies(req, info.Tags, func(gopath billy.Filesystem, skip map[string]bool) error {
+		indexes := []*importIndex{std, source}
+		if gopath != nil {
+			downloaded, err := newImportIndex(gopath, filepath.Join("gopath", "src"), skip)
+			if err != nil {
+				return err
+			}
+			indexes = append(indexes, downloaded)
+		}
+		// The index reads the editor's session as the imports are fixed, so the source is formatted
+		// while the session is locked.
+		complete = formatSource(info.Source, indexes)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	send(complete)
+
+	return nil
+}
+
+// formatSource formats the source packages. If indexes is not nil, the imports are also fixed.
+func formatSource(source map[string]map[string]string, indexes []*importIndex) messages.FormatComplete {
+	complete := messages.FormatComplete{Source: map[string]map[string]string{}}
+	for path, files := range source {
+		complete.Source[path] = map[string]string{}
+		formatted, errs := formatPackage(path, files, indexes)
+		for name, contents := range files {
+			if f, ok := formatted[name]; ok {
+				contents = f
+			}
+			complete.Source[path][name] = contents
+		}
+		complete.Errors = append(complete.Errors, errs...)
+	}
+	sort.Slice(complete.Errors, func(i, j int) bool {
+		a, b := complete.Errors[i], complete.Errors[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return complete
+}
+
+// formatPackage formats the Go files in a package. If indexes is not nil, the imports are also fixed.
+// Files with syntax errors are not returned.
+func formatPackage(path string, files map[string]string, indexes []*importIndex) (map[string]string, []messages.FormatError) {
+	fset := token.NewFileSet()
+	parsed := map[string]*ast.File{}
+	var errs []messages.FormatError
+	for name, contents := range files {
+		if !strings.HasSuffix(name, ".go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, name, contents, parser.ParseComments)
+		if err != nil {
+			errs = append(errs, getFormatErrors(path, name, err)...)
+			continue
+		}
+		parsed[name] = f
+	}
+
+	if indexes != nil {
+		// Names declared at package level in any file are not missing imports. Test files in an external
+		// test package don't share declarations with the package.
+		declared := map[string]map[string]bool{}
+		for _, f := range parsed {
+			if declared[f.Name.Name] == nil {
+				declared[f.Name.Name] = map[string]bool{}
+			}
+			for name := range f.Scope.Objects {
+				declared[f.Name.Name][name] = true
+			}
+		}
+		for _, f := range parsed {
+			fixImports(fset, f, path, declared[f.Name.Name], indexes)
+		}
+	}
+
+	formatted := map[string]string{}
+	for name, f := range parsed {
+		buf := &bytes.Buffer{}
+		if err := format.Node(buf, fset, f); err != nil {
+			errs = append(errs, messages.FormatError{Path: path, File: name, Message: err.Error()})
+			continue
+		}
+		formatted[name] = buf.String()
+	}
+	return formatted, errs
+}
+
+func getFormatErrors(path, name string, err error) []messages.FormatError {
+	list, ok := err.(scanner.ErrorList)
+	if !ok {
+		return []messages.FormatError{{Path: path, File: name, Message: err.Error()}}
+	}
+	var errs []messages.FormatError
+	for _, e := range list {
+		errs = append(errs, messages.FormatError{
+			Path:    path,
+			File:    name,
+			Line:    e.Pos.Line,
+			Column:  e.Pos.Column,
+			Message: e.Msg,
+		})
+	}
+	return errs
+}
+
+// fixImports removes unused imports and adds missing imports, like goimports.
+func fixImports(fset *token.FileSet, f *ast.File, path string, declared map[string]bool, indexes []*importIndex) {
+
+	// refs is the selectors used for each unresolved identifier, e.g. refs["fmt"]["Println"]
+	refs := map[string]map[string]bool{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		id, ok := sel.X.(*ast.Ident)
+		if !ok || id.Obj != nil {
+			return true
+		}
+		if refs[id.Name] == nil {
+			refs[id.Name] = map[string]bool{}
+		}
+		refs[id.Name][sel.Sel.Name] = true
+		return true
+	})
+
+	imported := map[string]bool{}
+	for _, spec := range f.Imports {
+		ipath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		var name string
+		var resolved bool
+		if spec.Name != nil {
+			name, resolved = spec.Name.Name, true
+		} else {
+			name, resolved = importName(ipath, indexes)
+		}
+		if name == "_" || name == "." || ipath == "C" {
+			continue
+		}
+		if refs[name] == nil && resolved {
+			// Like goimports, imports are only removed if the package name is known. A guessed name may
+			// be wrong, e.g. "github.com/satori/go.uuid" is package uuid.
+			var explicit string
+			if spec.Name != nil {
+				explicit = spec.Name.Name
+			}
+			astutil.DeleteNamedImport(fset, f, explicit, ipath)
+			continue
+		}
+		imported[name] = true
+	}
+
+	var missing []string
+	for name := range refs {
+		if imported[name] || declared[name] || types.Universe.Lookup(name) != nil {
+			continue
+		}
+		missing = append(missing, name)
+	}
+	sort.Strings(missing)
+
+	for _, name := range missing {
+		ipath := findImport(name, refs[name], path, indexes)
+		if ipath == "" {
+			continue
+		}
+		if name == defaultImportName(ipath) {
+			astutil.AddImport(fset, f, ipath)
+		} else {
+			astutil.AddNamedImport(fset, f, name, ipath)
+		}
+	}
+}
+
+// importName returns the package name of an import path, or a guess based on the path and false if the
+// package isn't found.
+func importName(path string, indexes []*importIndex) (string, bool) {
+	for _, index := range indexes {
+		if name, ok := index.paths[path]; ok {
+			return name, true
+		}
+	}
+	return defaultImportName(path), false
+}
+
+// defaultImportName guesses the package name from the import path, e.g. "github.com/foo/go-bar.v2" is
+// "bar".
+func defaultImportName(path string) string {
+	name := path[strings.LastIndex(path, "/")+1:]
+	if i := strings.Index(name, "."); i > 0 {
+		name = name[:i]
+	}
+	name = strings.TrimPrefix(name, "go-")
+	return strings.Replace(name, "-", "_", -1)
+}
+
+// findImport returns the import path of a package with the name that exports all the symbols, or ""
+// if none is found. Earlier indexes are preferred, then shorter paths.
+func findImport(name string, symbols map[string]bool, from string, indexes []*importIndex) string {
+	for _, index := range indexes {
+		candidates := append([]string(nil), index.names[name]...)
+		sort.Slice(candidates, func(i, j int) bool {
+			if len(candidates[i]) != len(candidates[j]) {
+				return len(candidates[i]) < len(candidates[j])
+			}
+			return candidates[i] < candidates[j]
+		})
+		for _, path := range candidates {
+			if path == from {
+				continue
+			}
+			exports, err := index.exports(path)
+			if err != nil {
+				continue
+			}
+			found := true
+			for symbol := range symbols {
+				if !exports[symbol] {
+					found = false
+					break
+				}
+			}
+			if found {
+				return path
+			}
+		}
+	}
+	return ""
+}
+
+// importIndex is the packages below root in a filesystem.
+type importIndex struct {
+	fs    billy.Filesystem
+	root  string
+	skip  map[string]bool     // Import paths that aren't indexed
+	names map[string][]string // package name -> import paths
+	paths map[string]string   // import path -> package name
+
+	m        sync.Mutex
+	exported map[string]map[string]bool // import path -> exported names
+}
+
+var stdIndex struct {
+	once  sync.Once
+	index *importIndex
+	err   error
+}
+
+// getStdIndex returns the index of the standard library in assets.Assets. It's only created once.
+func getStdIndex() (*importIndex, error) {
+	stdIndex.once.Do(func() {
+		stdIndex.index, stdIndex.err = newImportIndex(assets.Assets, filepath.Join("goroot", "src"), nil)
+	})
+	return stdIndex.index, stdIndex.err
+}
+
+func newImportIndex(fs billy.Filesystem, root string, skip map[string]bool) (*importIndex, error) {
+	index := &importIndex{
+		fs:       fs,
+		root:     root,
+		skip:     skip,
+		names:    map[string][]string{},
+		paths:    map[string]string{},
+		exported: map[string]map[string]bool{},
+	}
+	if _, err := fs.Stat(root); err != nil {
+		// The GOPATH may be empty
+		return index, nil
+	}
+	if err := index.walk(""); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+func (i *importIndex) walk(path string) error {
+	fis, err := i.fs.ReadDir(filepath.Join(i.root, path))
+	if err != nil {
+		return err
+	}
+	var found bool
+	for _, fi := range fis {
+		if fi.IsDir() {
+			switch fi.Name() {
+			case "internal", "vendor", "testdata", "cmd":
+				continue
+			}
+			if strings.HasPrefix(fi.Name(), ".") || strings.HasPrefix(fi.Name(), "_") {
+				continue
+			}
+			if err := i.walk(pathJoin(path, fi.Name())); err != nil {
+				return err
+			}
+			continue
+		}
+		if found || path == "" || i.skip[path] || !isPackageFile(fi.Name()) {
+			continue
+		}
+		b, err := i.read(pathJoin(path, fi.Name()))
+		if err != nil {
+			return err
+		}
+		f, err := parser.ParseFile(token.NewFileSet(), fi.Name(), b, parser.PackageClauseOnly)
+		if err != nil {
+			continue
+		}
+		if f.Name.Name == "main" || f.Name.Name == "documentation" {
+			continue
+		}
+		found = true
+		i.names[f.Name.Name] = append(i.names[f.Name.Name], path)
+		i.paths[path] = f.Name.Name
+	}
+	return nil
+}
+
+// exports returns the exported top level names in a package.
+func (i *importIndex) exports(path string) (map[string]bool, error) {
+	i.m.Lock()
+	defer i.m.Unlock()
+	if exports, ok := i.exported[path]; ok {
+		return exports, nil
+	}
+	fis, err := i.fs.ReadDir(filepath.Join(i.root, path))
+	if err != nil {
+		return nil, err
+	}
+	exports := map[string]bool{}
+	for _, fi := range fis {
+		if fi.IsDir() || !isPackageFile(fi.Name()) {
+			continue
+		}
+		b, err := i.read(pathJoin(path, fi.Name()))
+		if err != nil {
+			return nil, err
+		}
+		f, err := parser.ParseFile(token.NewFileSet(), fi.Name(), b, 0)
+		if err != nil || f.Name.Name != i.paths[path] {
+			continue
+		}
+		for name := range f.Scope.Objects {
+			if ast.IsExported(name) {
+				exports[name] = true
+			}
+		}
+	}
+	i.exported[path] = exports
+	return exports, nil
+}
+
+func (i *importIndex) read(name string) ([]byte, error) {
+	f, err := i.fs.Open(filepath.Join(i.root, name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+func isPackageFile(name string) bool {
+	return strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, "_test.go")
+}
+
+func pathJoin(a, b string) string {
+	if a == "" {
+		return b
+	}
+	return a + "/" + b
+}
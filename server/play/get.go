@@ -27,36 +27,52 @@ import (
 
 func (h *Handler) Get(ctx context.Context, info messages.Get, req *http.Request, send func(message services.Message), receive chan services.Message) error {
 	s := session.New(nil, assets.Assets, assets.Archives, h.Fileserver, config.ValidExtensions)
-	g := get.New(s, send, h.Cache.NewRequest(false))
-	_, err := getSource(ctx, g, s, info.Path, send)
+	gitcache, _, err := h.Credentials.Cache(ctx, req, h.Cache)
 	if err != nil {
 		return err
 	}
+	g := get.New(s, send, gitcache.NewRequest(false))
+	if _, _, err := h.getSource(ctx, g, s, info.Path, info.Tests, send); err != nil {
+		return err
+	}
 	return nil
 }
 
-func getSource(ctx context.Context, g *get.Getter, s *session.Session, path string, send func(message services.Message)) (map[string]map[string]string, error) {
+// getSource gets the source for path, and sends it in a GetComplete. Shares also return the build tags
+// they were shared with.
+func (h *Handler) getSource(ctx context.Context, g *get.Getter, s *session.Session, path string, tests bool, send func(message services.Message)) (map[string]map[string]string, []string, error) {
+
+	if strings.HasPrefix(path, "share/") {
+		send(gettermsg.Downloading{Message: path})
+		sp, err := h.getShare(ctx, strings.TrimPrefix(path, "share/"))
+		if err != nil {
+			return nil, nil, err
+		}
+		send(gettermsg.Downloading{Done: true})
+		send(messages.GetComplete{Source: sp.Source, Tags: sp.Tags})
+		return sp.Source, sp.Tags, nil
+	}
 
 	if strings.HasPrefix(path, "p/") {
 		send(gettermsg.Downloading{Message: path})
 		source, err := getGolangPlaygroundSource(ctx, path)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		send(gettermsg.Downloading{Done: true})
 		send(messages.GetComplete{Source: source})
-		return source, nil
+		return source, nil, nil
 	}
 
 	root := filepath.Join("goroot", "src", path)
 	if _, err := assets.Assets.Stat(root); err == nil {
 		// Look in the goroot for standard lib packages
-		source, err := getSourceFiles(assets.Assets, path, root)
+		source, err := getSourceFiles(assets.Assets, path, root, tests)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		send(messages.GetComplete{Source: source})
-		return source, nil
+		return source, nil, nil
 	}
 
 	// Send a message to the client that downloading step has started.
@@ -68,22 +84,22 @@ func getSource(ctx context.Context, g *get.Getter, s *session.Session, path stri
 	// Start the download process - just like the "go get" command.
 	// Don't need to give git hints here because only one package will be downloaded
 	if err := g.Get(ctx, path, false, insecure, true); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	source, err := getSourceFiles(s.GoPath(), path, filepath.Join("gopath", "src", path))
+	source, err := getSourceFiles(s.GoPath(), path, filepath.Join("gopath", "src", path), tests)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Send a message to the client that downloading step has finished.
 	send(gettermsg.Downloading{Done: true})
 	send(messages.GetComplete{Source: source})
 
-	return source, nil
+	return source, nil, nil
 }
 
-func getSourceFiles(fs billy.Filesystem, path, dir string) (map[string]map[string]string, error) {
+func getSourceFiles(fs billy.Filesystem, path, dir string, tests bool) (map[string]map[string]string, error) {
 	source := map[string]map[string]string{}
 	fis, err := fs.ReadDir(dir)
 	if err != nil {
@@ -93,7 +109,7 @@ func getSourceFiles(fs billy.Filesystem, path, dir string) (map[string]map[strin
 		if !isValidFile(fi.Name()) {
 			continue
 		}
-		if strings.HasSuffix(fi.Name(), "_test.go") {
+		if !tests && strings.HasSuffix(fi.Name(), "_test.go") {
 			continue
 		}
 		f, err := fs.Open(filepath.Join(dir, fi.Name()))
@@ -139,10 +155,5 @@ func getGolangPlaygroundSource(ctx context.Context, path string) (map[string]map
 	if err != nil {
 		return nil, err
 	}
-	source := map[string]map[string]string{
-		path: {
-			"main.go": string(b),
-		},
-	}
-	return source, nil
+	return parseTxtar(path, b)
 }
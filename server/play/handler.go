@@ -19,14 +19,18 @@ import (
 	"github.com/dave/services/tracker"
 
 	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/credentials"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/editor"
 	"github.com/sniperkit/snk.fork.dave-jsgo/server/play/messages"
 	"github.com/sniperkit/snk.fork.dave-jsgo/server/store"
 )
 
 type Handler struct {
-	Cache      *cache.Cache
-	Fileserver services.Fileserver
-	Database   services.Database
+	Cache       *cache.Cache
+	Fileserver  services.Fileserver
+	Database    services.Database
+	Credentials *credentials.Credentials
+	Editor      *editor.Editor
 }
 
 func (h *Handler) Handle(ctx context.Context, req *http.Request, send func(message services.Message), receive chan services.Message, tj *tracker.Job) error {
@@ -44,6 +48,20 @@ func (h *Handler) Handle(ctx context.Context, req *http.Request, send func(messa
 			return h.Deploy(ctx, m, req, send, receive)
 		case messages.Initialise:
 			return h.Initialise(ctx, m, req, send, receive)
+		case messages.Test:
+			return h.Test(ctx, m, req, send, receive)
+		case messages.Format:
+			return h.Format(ctx, m, req, send, receive)
+		case messages.Check:
+			return h.Check(ctx, m, req, send, receive)
+		case messages.Complete:
+			return h.Complete(ctx, m, req, send, receive)
+		case messages.Hover:
+			return h.Hover(ctx, m, req, send, receive)
+		case messages.History:
+			return h.History(ctx, m, req, send, receive)
+		case messages.Export:
+			return h.Txtar(ctx, m, req, send, receive)
 		default:
 			return fmt.Errorf("invalid init message %T", m)
 		}
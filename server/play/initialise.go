@@ -19,23 +19,36 @@ import (
 	"github.com/sniperkit/snk.fork.dave-jsgo/assets/std"
 	"github.com/sniperkit/snk.fork.dave-jsgo/config"
 	"github.com/sniperkit/snk.fork.dave-jsgo/server/play/messages"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/serverconfig"
 )
 
 func (h *Handler) Initialise(ctx context.Context, info messages.Initialise, req *http.Request, send func(message services.Message), receive chan services.Message) error {
 
 	s := session.New(nil, assets.Assets, assets.Archives, h.Fileserver, config.ValidExtensions)
 
-	gitreq := h.Cache.NewRequest(true)
+	gitcache, private, err := h.Credentials.Cache(ctx, req, h.Cache)
+	if err != nil {
+		return err
+	}
+
+	gitreq := gitcache.NewRequest(!private)
 	if err := gitreq.InitialiseFromHints(ctx, info.Path); err != nil {
 		return err
 	}
 	g := get.New(s, send, gitreq)
 
-	source, err := getSource(ctx, g, s, info.Path, send)
+	source, tags, err := h.getSource(ctx, g, s, info.Path, false, send)
 	if err != nil {
 		return err
 	}
 
+	if len(tags) > 0 {
+		// Shares are built with the tags they were shared with. The source of a share isn't downloaded
+		// to the session, so the session can be replaced.
+		s = session.New(tags, assets.Assets, assets.Archives, h.Fileserver, config.ValidExtensions)
+		g = get.New(s, send, gitreq)
+	}
+
 	if err := s.SetSource(source); err != nil {
 		return err
 	}
@@ -43,9 +56,12 @@ func (h *Handler) Initialise(ctx context.Context, info messages.Initialise, req
 	// set insecure = true in local mode or it will fail if git repo has git protocol
 	insecure := config.LOCAL
 
-	// Start the download process - just like the "go get" command.
-	if err := g.Get(ctx, info.Path, false, insecure, false); err != nil {
-		return err
+	// Start the download process - just like the "go get" command. Shares may contain several packages,
+	// and aren't keyed by info.Path.
+	for path := range source {
+		if err := g.Get(ctx, path, false, insecure, false); err != nil {
+			return err
+		}
 	}
 
 	if err := gitreq.Close(ctx); err != nil {
@@ -55,7 +71,7 @@ func (h *Handler) Initialise(ctx context.Context, info messages.Initialise, req
 	// Send a message to the client that downloading step has finished.
 	send(gettermsg.Downloading{Done: true})
 
-	if err := deployer.New(s, send, std.Index, std.Prelude, config.DeployerConfig).Update(ctx, source, map[string]string{}, info.Minify); err != nil {
+	if err := deployer.New(s, send, std.Index, std.Prelude, serverconfig.DeployerConfig).Update(ctx, source, map[string]string{}, info.Minify); err != nil {
 		return err
 	}
 
@@ -0,0 +1,50 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package play
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dave/services"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/cdn"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/store"
+)
+
+// Manifest serves the manifest of a deploy at /_manifest/<index> as JSON, with a Link header that
+// preloads the prelude and packages.
+func Manifest(w http.ResponseWriter, req *http.Request, database services.Database) {
+
+	ctx, cancel := context.WithTimeout(req.Context(), config.PageTimeout)
+	defer cancel()
+
+	index := strings.TrimPrefix(req.URL.Path, "/_manifest/")
+
+	found, data, err := store.Deploy(ctx, database, index)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	if !found {
+		http.Error(w, fmt.Sprintf("deploy %s not found", index), 404)
+		return
+	}
+
+	manifest := cdn.NewManifest("", data.Contents.Main, "", "", data.Contents.Packages)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Link", manifest.LinkHeader())
+	if err := json.NewEncoder(w).Encode(manifest); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+}
@@ -34,6 +34,13 @@ var payloads = []interface{}{
 	ShareComplete{},
 	GetComplete{},
 	DeployComplete{},
+	TestComplete{},
+	FormatComplete{},
+	CheckComplete{},
+	Completions{},
+	HoverComplete{},
+	HistoryComplete{},
+	ExportComplete{},
 
 	deployermsg.Archive{},
 	deployermsg.ArchiveIndex{},
@@ -44,6 +51,13 @@ var payloads = []interface{}{
 	Get{},
 	Deploy{},
 	Initialise{},
+	Test{},
+	Format{},
+	Check{},
+	Complete{},
+	Hover{},
+	History{},
+	Export{},
 }
 
 type DeployComplete struct {
@@ -62,15 +76,29 @@ type Update struct {
 
 // Share is sent by the client to persist the setup on the server.
 type Share struct {
-	Source map[string]map[string]string
-	Tags   []string
+	Source     map[string]map[string]string
+	Tags       []string
+	Parent     string // Hash of the share that was loaded and edited, if any
+	ExpireDays int    // Delete the share after this many days. Zero never expires.
+}
+
+// History is sent by the client to get the history of a share by following the parents.
+type History struct {
+	Hash string
+}
+
+// HistoryComplete is sent in response to History. Hashes starts with the requested share and is
+// followed by its parent, grandparent etc.
+type HistoryComplete struct {
+	Hashes []string
 }
 
 type Deploy struct {
-	Main    string
-	Imports []string
-	Source  map[string]map[string]string // Source packages for this build: map[<package>]map[<filename>]<contents>
-	Tags    []string
+	Main     string
+	Imports  []string
+	Source   map[string]map[string]string // Source packages for this build: map[<package>]map[<filename>]<contents>
+	Tags     []string
+	NoMinify bool // Deploy un-minified JS (the playground always deploys minified JS)
 }
 
 // Initialise is sent by the client to get the source at Path, and update.
@@ -79,17 +107,111 @@ type Initialise struct {
 	Minify bool
 }
 
-// Get is sent by the client to the server asking it to download a package and return the source.
+// Get is sent by the client to the server asking it to download a package and return the source. Path
+// may also be "p/<id>" for a play.golang.org snippet or "share/<hash>" for a share.
 type Get struct {
-	Path string
+	Path  string
+	Tests bool // Include the _test.go files
+}
+
+// Test is sent by the client to build the tests of a package. The internal and external tests are
+// compiled with a generated test main package, and the archives are returned as for Update.
+type Test struct {
+	Path    string                       // Package to test
+	Source  map[string]map[string]string // Source packages for this build: map[<package>]map[<filename>]<contents>
+	Tags    []string                     // Build tags
+	Cache   map[string]string            // Map of path->hash of previously compiled dependencies to use if still in the cache
+	Minify  bool
+	Run     string // Only run tests and examples matching this regular expression, like "go test -run"
+	Verbose bool   // Log all tests, like "go test -v"
+}
+
+// TestComplete is sent when the tests have been built.
+type TestComplete struct {
+	Main string // Path of the generated test main package
 }
 
 type GetComplete struct {
 	Source map[string]map[string]string
+	Tags   []string // Build tags of a share
 }
 
 type ShareComplete struct {
-	Hash string
+	Hash        string
+	DeleteToken string // Secret that allows the owner to delete the share. Empty if the share already existed.
+}
+
+// Format is sent by the client to format the source like gofmt. With Imports, missing imports are also
+// added and unused imports removed, like goimports.
+type Format struct {
+	Source  map[string]map[string]string // Source packages: map[<package>]map[<filename>]<contents>
+	Tags    []string                     // Build tags
+	Imports bool
+}
+
+// FormatComplete is sent when the source has been formatted. Files with syntax errors are returned
+// unchanged, and the errors are in Errors.
+type FormatComplete struct {
+	Source map[string]map[string]string
+	Errors []FormatError
+}
+
+type FormatError struct {
+	Path    string // Package path
+	File    string // File name
+	Line    int
+	Column  int
+	Message string
+}
+
+// Check is sent by the client to type check the source and run static analysis passes over it, so
+// problems can be shown before compiling.
+type Check struct {
+	Source map[string]map[string]string // Source packages: map[<package>]map[<filename>]<contents>
+	Tags   []string                     // Build tags
+}
+
+// CheckComplete is sent when the source has been checked.
+type CheckComplete struct {
+	Diagnostics []servermsg.Diagnostic
+}
+
+// Complete is sent by the client to get the identifiers that complete the code at Offset in File.
+type Complete struct {
+	Source map[string]map[string]string // Source packages: map[<package>]map[<filename>]<contents>
+	Tags   []string                     // Build tags
+	Path   string                       // Package containing the cursor
+	File   string                       // File containing the cursor
+	Offset int                          // Byte offset of the cursor
+}
+
+// Completions is sent in response to Complete.
+type Completions struct {
+	Candidates []servermsg.Candidate
+}
+
+// Hover is sent by the client to get the signature and doc comment of the identifier at Offset in File.
+type Hover struct {
+	Source map[string]map[string]string // Source packages: map[<package>]map[<filename>]<contents>
+	Tags   []string                     // Build tags
+	Path   string                       // Package containing the cursor
+	File   string                       // File containing the cursor
+	Offset int                          // Byte offset of the cursor
+}
+
+// HoverComplete is sent in response to Hover. Info is nil if there's no identifier at the cursor.
+type HoverComplete struct {
+	Info *servermsg.HoverInfo
+}
+
+// Export is sent by the client to convert the source to the txtar format used by play.golang.org.
+type Export struct {
+	Source map[string]map[string]string
+}
+
+// ExportComplete is sent in response to Export.
+type ExportComplete struct {
+	Txtar string
 }
 
 func Marshal(in services.Message) ([]byte, int, error) {
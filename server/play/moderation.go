@@ -0,0 +1,200 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package play
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dave/services"
+	"github.com/dave/services/constor"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/store"
+)
+
+// ErrShareNotFound is returned for shares that have been deleted, have expired or have been blocked.
+var ErrShareNotFound = errors.New("share not found")
+
+// checkShare returns ErrShareNotFound if the share has been removed. Expired shares are removed when
+// they're next requested, or by ExpireShares.
+func checkShare(ctx context.Context, fileserver services.Fileserver, database services.Database, hash string) error {
+	found, state, err := store.Share(ctx, database, hash)
+	if err != nil {
+		return err
+	}
+	if !found {
+		// Shares from before ShareState was added never expire
+		return nil
+	}
+	if state.Deleted || state.Blocked {
+		return ErrShareNotFound
+	}
+	if expired(state) {
+		if err := expireShare(ctx, fileserver, database, hash); err != nil {
+			return err
+		}
+		return ErrShareNotFound
+	}
+	return nil
+}
+
+func expired(state store.ShareState) bool {
+	return !state.Expires.IsZero() && time.Now().After(state.Expires)
+}
+
+// expireShare marks an expired share as deleted, and removes it.
+func expireShare(ctx context.Context, fileserver services.Fileserver, database services.Database, hash string) error {
+	if err := store.UpdateShareState(ctx, database, hash, func(state *store.ShareState, found bool) error {
+		if !found || state.Deleted || state.Blocked || !expired(*state) {
+			// Changed since it was read (e.g. shared again), so it's not removed.
+			return errUnchanged
+		}
+		state.Deleted = true
+		return nil
+	}); err != nil {
+		if err == errUnchanged {
+			return nil
+		}
+		return err
+	}
+	return removeShare(ctx, fileserver, hash)
+}
+
+// errUnchanged is returned from an update function when there's nothing to store.
+var errUnchanged = errors.New("unchanged")
+
+// DeleteShare deletes a share if the token matches the delete token that was returned to the owner.
+func DeleteShare(ctx context.Context, fileserver services.Fileserver, database services.Database, hash, token string) error {
+	if !shareHash.MatchString(hash) {
+		return ErrShareNotFound
+	}
+	found, owner, err := store.Owner(ctx, database, hashDeleteToken(token))
+	if err != nil {
+		return err
+	}
+	if !found || owner.Hash != hash {
+		return errors.New("invalid delete token")
+	}
+	if err := store.UpdateShareState(ctx, database, hash, func(state *store.ShareState, found bool) error {
+		if !found || state.Deleted || state.Blocked {
+			return ErrShareNotFound
+		}
+		state.Deleted = true
+		return nil
+	}); err != nil {
+		return err
+	}
+	return removeShare(ctx, fileserver, hash)
+}
+
+// ExpireShares removes the shares that have expired. The play client reads shares from the src bucket,
+// so expired shares are removed periodically rather than only when they're requested from the server.
+func ExpireShares(ctx context.Context, fileserver services.Fileserver, database services.Database, lister store.Lister) error {
+	states, err := lister.ListExpiredShares(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	for _, state := range states {
+		if err := expireShare(ctx, fileserver, database, state.Hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReportShare records an abuse report. Reported shares are listed on the admin page.
+func ReportShare(ctx context.Context, fileserver services.Fileserver, database services.Database, hash, reason, ip string) error {
+	if !shareHash.MatchString(hash) {
+		return ErrShareNotFound
+	}
+	found, _, err := store.Share(ctx, database, hash)
+	if err != nil {
+		return err
+	}
+	if !found {
+		// Shares from before ShareState was added have no state
+		exists, err := fileserver.Exists(ctx, config.Bucket[config.Src], shareName(hash))
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrShareNotFound
+		}
+	}
+	if len(reason) > config.MaxReportReason {
+		reason = reason[:config.MaxReportReason]
+	}
+	return store.UpdateShareState(ctx, database, hash, func(state *store.ShareState, found bool) error {
+		if !found {
+			*state = store.ShareState{Hash: hash, Time: time.Now()}
+		}
+		if state.Deleted || state.Blocked {
+			return ErrShareNotFound
+		}
+		state.ReportCount++
+		if len(state.Reports) < config.MaxReports {
+			state.Reports = append(state.Reports, store.ShareReport{Time: time.Now(), Reason: reason, Ip: ip})
+		}
+		return nil
+	})
+}
+
+// BlockShare removes a share and stops the same source being shared again.
+func BlockShare(ctx context.Context, fileserver services.Fileserver, database services.Database, hash string) error {
+	if !shareHash.MatchString(hash) {
+		return fmt.Errorf("invalid share %q", hash)
+	}
+	if err := store.UpdateShareState(ctx, database, hash, func(state *store.ShareState, found bool) error {
+		if !found {
+			*state = store.ShareState{Hash: hash, Time: time.Now()}
+		}
+		state.Blocked = true
+		return nil
+	}); err != nil {
+		return err
+	}
+	return removeShare(ctx, fileserver, hash)
+}
+
+// removeShare removes the share from the src bucket. services.Fileserver has no delete operation, so
+// unless the fileserver can delete, the object is overwritten with an empty share pack.
+func removeShare(ctx context.Context, fileserver services.Fileserver, hash string) error {
+	if d, ok := fileserver.(interface {
+		Delete(ctx context.Context, bucket, name string) error
+	}); ok {
+		return d.Delete(ctx, config.Bucket[config.Src], shareName(hash))
+	}
+	b, err := json.Marshal(sharePack{Version: 1})
+	if err != nil {
+		return err
+	}
+	if _, err := fileserver.Write(ctx, config.Bucket[config.Src], shareName(hash), bytes.NewReader(b), true, constor.MimeJson, "no-cache"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// newDeleteToken returns a random delete token. Only the sha256 of the token is stored.
+func newDeleteToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hashDeleteToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
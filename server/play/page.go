@@ -7,7 +7,6 @@ package play
 
 import (
 	"context"
-	"fmt"
 	"html/template"
 	"net/http"
 	"runtime"
@@ -16,6 +15,7 @@ import (
 	"github.com/dave/services"
 
 	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/cdn"
 	"github.com/sniperkit/snk.fork.dave-jsgo/server/store"
 )
 
@@ -37,7 +37,7 @@ func Page(w http.ResponseWriter, req *http.Request, database services.Database)
 			http.Error(w, "play package not found", 500)
 			return
 		}
-		url = fmt.Sprintf("https://pkg.jsgo.io/github.com/dave/play.%s.js", c.Min.Main)
+		url = cdn.PackageUrl("github.com/dave/play", c.Min.Main)
 	}
 
 	v := struct {
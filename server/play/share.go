@@ -10,30 +10,81 @@ import (
 	"context"
 	"crypto/sha1"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"regexp"
 	"time"
 
-	"cloud.google.com/go/storage"
-	"github.com/dave/play/models"
 	"github.com/dave/services"
 	"github.com/dave/services/constor"
 	"github.com/dave/services/constor/constormsg"
 
 	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/cdn"
 	"github.com/sniperkit/snk.fork.dave-jsgo/server/play/messages"
 	"github.com/sniperkit/snk.fork.dave-jsgo/server/store"
 )
 
+// sharePack is the object stored in the src bucket when the source is shared. Version 0 was
+// models.SharePack from github.com/dave/play. Version 1 adds Parent, so the forks of a share form a
+// history.
+type sharePack struct {
+	Version int
+	Source  map[string]map[string]string
+	Tags    []string
+	Parent  string `json:",omitempty"` // Hash of the share this was forked from
+}
+
+// shareHash matches the hash of a share, which is the hex encoded sha1 of the share pack.
+var shareHash = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+func shareName(hash string) string {
+	return fmt.Sprintf("%s.json", hash)
+}
+
+// getShare reads a share pack from the src bucket.
+func (h *Handler) getShare(ctx context.Context, hash string) (*sharePack, error) {
+	if !shareHash.MatchString(hash) {
+		return nil, fmt.Errorf("invalid share %q", hash)
+	}
+	if err := checkShare(ctx, h.Fileserver, h.Database, hash); err != nil {
+		return nil, err
+	}
+	b, err := cdn.Read(ctx, h.Fileserver, config.Bucket[config.Src], shareName(hash))
+	if err != nil {
+		return nil, err
+	}
+	var sp sharePack
+	if err := json.Unmarshal(b, &sp); err != nil {
+		return nil, err
+	}
+	return &sp, nil
+}
+
 func (h *Handler) Share(ctx context.Context, info messages.Share, req *http.Request, send func(message services.Message), receive chan services.Message) error {
 
 	send(constormsg.Storing{Starting: true})
 
-	sp := models.SharePack{
-		Version: 0,
+	if info.Parent != "" {
+		if !shareHash.MatchString(info.Parent) {
+			return fmt.Errorf("invalid parent share %q", info.Parent)
+		}
+		exists, err := h.Fileserver.Exists(ctx, config.Bucket[config.Src], shareName(info.Parent))
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("parent share %s not found", info.Parent)
+		}
+	}
+
+	sp := sharePack{
+		Version: 1,
 		Source:  info.Source,
 		Tags:    info.Tags,
+		Parent:  info.Parent,
 	}
 
 	buf := &bytes.Buffer{}
@@ -42,40 +93,76 @@ func (h *Handler) Share(ctx context.Context, info messages.Share, req *http.Requ
 	if err := json.NewEncoder(w).Encode(sp); err != nil {
 		return err
 	}
-	hash := sha.Sum(nil)
+	hash := fmt.Sprintf("%x", sha.Sum(nil))
 
-	client, err := storage.NewClient(ctx)
+	found, state, err := store.Share(ctx, h.Database, hash)
 	if err != nil {
 		return err
 	}
-	defer client.Close()
-
-	storer := constor.New(ctx, h.Fileserver, send, config.ConcurrentStorageUploads)
-	storer.Add(constor.Item{
-		Message:   "source",
-		Name:      fmt.Sprintf("%x.json", hash),
-		Contents:  buf.Bytes(),
-		Bucket:    config.Bucket[config.Src],
-		Mime:      constor.MimeJson,
-		Count:     true,
-		Immutable: true,
-		Send:      true,
-	})
-	if err := storer.Wait(); err != nil {
+	if found && state.Blocked {
+		return errBlocked
+	}
+
+	// The request owns the share if it's new, or if it was deleted or has expired.
+	owner := !found || state.Deleted || expired(state)
+
+	// Any share can be deleted or blocked, so none are cached as immutable: the CDN must stop serving
+	// them when they're removed. A removed share is overwritten when it's shared again.
+	if _, err := h.Fileserver.Write(ctx, config.Bucket[config.Src], shareName(hash), bytes.NewReader(buf.Bytes()), owner, constor.MimeJson, "no-cache"); err != nil {
 		return err
 	}
 
 	send(constormsg.Storing{Done: true})
 
-	if err := h.storeShare(ctx, info.Source, fmt.Sprintf("%x", hash), send, req); err != nil {
+	if err := h.storeShare(ctx, info.Source, hash, send, req); err != nil {
 		return err
 	}
 
-	send(messages.ShareComplete{Hash: fmt.Sprintf("%x", hash)})
+	var token string
+	if owner {
+		if token, err = newDeleteToken(); err != nil {
+			return err
+		}
+		// Each token is stored separately, so if the source is shared by two requests at the same time
+		// both tokens delete the share.
+		if err := store.StoreShareOwner(ctx, h.Database, hashDeleteToken(token), store.ShareOwner{
+			Hash: hash,
+			Time: time.Now(),
+			Ip:   req.Header.Get("X-Forwarded-For"),
+		}); err != nil {
+			return err
+		}
+		if err := store.UpdateShareState(ctx, h.Database, hash, func(state *store.ShareState, found bool) error {
+			if found && state.Blocked {
+				return errBlocked
+			}
+			*state = store.ShareState{
+				Hash: hash,
+				Time: time.Now(),
+				Ip:   req.Header.Get("X-Forwarded-For"),
+			}
+			if info.ExpireDays > 0 {
+				state.Expires = state.Time.Add(time.Duration(info.ExpireDays) * 24 * time.Hour)
+			}
+			return nil
+		}); err != nil {
+			if err == errBlocked {
+				// Blocked while it was being stored, so the source is removed again.
+				if err := removeShare(ctx, h.Fileserver, hash); err != nil {
+					return err
+				}
+			}
+			return err
+		}
+	}
+
+	send(messages.ShareComplete{Hash: hash, DeleteToken: token})
 
 	return nil
 }
 
+var errBlocked = errors.New("this source has been removed and can't be shared again")
+
 func (h *Handler) storeShare(ctx context.Context, source map[string]map[string]string, hash string, send func(services.Message), req *http.Request) error {
 	var count int
 	for _, pkg := range source {
@@ -94,3 +181,21 @@ func (h *Handler) storeShare(ctx context.Context, source map[string]map[string]s
 	}
 	return nil
 }
+
+func (h *Handler) History(ctx context.Context, info messages.History, req *http.Request, send func(message services.Message), receive chan services.Message) error {
+	var hashes []string
+	for hash := info.Hash; hash != "" && len(hashes) < config.MaxShareHistory; {
+		sp, err := h.getShare(ctx, hash)
+		if err == ErrShareNotFound && len(hashes) > 0 {
+			// The history ends at a deleted parent
+			break
+		}
+		if err != nil {
+			return err
+		}
+		hashes = append(hashes, hash)
+		hash = sp.Parent
+	}
+	send(messages.HistoryComplete{Hashes: hashes})
+	return nil
+}
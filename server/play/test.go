@@ -0,0 +1,328 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package play
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/doc"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/dave/services"
+	"github.com/dave/services/deployer"
+	"github.com/dave/services/session"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/assets"
+	"github.com/sniperkit/snk.fork.dave-jsgo/assets/std"
+	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/play/messages"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/serverconfig"
+)
+
+func (h *Handler) Test(ctx context.Context, info messages.Test, req *http.Request, send func(message services.Message), receive chan services.Message) error {
+
+	source, main, err := getTestSource(info)
+	if err != nil {
+		return err
+	}
+
+	s := session.New(info.Tags, assets.Assets, assets.Archives, h.Fileserver, config.ValidExtensions)
+
+	if err := s.SetSource(source); err != nil {
+		return err
+	}
+
+	// The test files have been renamed so the test dependencies are downloaded too.
+	if err := h.download(ctx, s, source, req, send); err != nil {
+		return err
+	}
+
+	if err := deployer.New(s, send, std.Index, std.Prelude, serverconfig.DeployerConfig).Update(ctx, source, info.Cache, info.Minify); err != nil {
+		return err
+	}
+
+	send(messages.TestComplete{Main: main})
+
+	return nil
+}
+
+// getTestSource returns the source with the tests of info.Path compiled into a test main package, and
+// the path of the test main package. The internal test files are renamed so they're built with the
+// package, and the external test files are renamed and moved to a separate package.
+func getTestSource(info messages.Test) (map[string]map[string]string, string, error) {
+	files, ok := info.Source[info.Path]
+	if !ok {
+		return nil, "", fmt.Errorf("package %s not found in source", info.Path)
+	}
+
+	xpath := info.Path + "_test"
+	main := info.Path + ".test"
+
+	source := map[string]map[string]string{}
+	for path, files := range info.Source {
+		if path == xpath || path == main {
+			return nil, "", fmt.Errorf("package %s conflicts with the generated test packages", path)
+		}
+		if path == info.Path {
+			continue
+		}
+		source[path] = files
+	}
+	source[info.Path] = map[string]string{}
+
+	fset := token.NewFileSet()
+	var name string
+	var internal, external []*ast.File
+	var testNames []string
+	for fname, contents := range files {
+		if !strings.HasSuffix(fname, "_test.go") {
+			source[info.Path][fname] = contents
+			if strings.HasSuffix(fname, ".go") && name == "" {
+				f, err := parser.ParseFile(fset, fname, contents, parser.PackageClauseOnly)
+				if err != nil {
+					return nil, "", err
+				}
+				name = f.Name.Name
+			}
+			continue
+		}
+		testNames = append(testNames, fname)
+	}
+	if len(testNames) == 0 {
+		return nil, "", fmt.Errorf("no test files in %s", info.Path)
+	}
+	sort.Strings(testNames)
+
+	for _, fname := range testNames {
+		contents := files[fname]
+		f, err := parser.ParseFile(fset, fname, contents, parser.ParseComments)
+		if err != nil {
+			return nil, "", err
+		}
+		if strings.HasSuffix(f.Name.Name, "_test") && f.Name.Name != name {
+			external = append(external, f)
+			if source[xpath] == nil {
+				source[xpath] = map[string]string{}
+			}
+			source[xpath][testFileName(fname)] = contents
+			continue
+		}
+		internal = append(internal, f)
+		source[info.Path][testFileName(fname)] = contents
+	}
+
+	data := testMainData{Run: info.Run, Verbose: info.Verbose}
+	if len(internal) > 0 {
+		data.Internal = &testPackage{Path: info.Path}
+		data.Internal.load("_test", internal)
+	}
+	if len(external) > 0 {
+		data.External = &testPackage{Path: xpath}
+		data.External.load("_xtest", external)
+	}
+	if data.Internal != nil && data.Internal.TestMain && data.External != nil && data.External.TestMain {
+		return nil, "", fmt.Errorf("multiple definitions of TestMain in %s", info.Path)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := testMainTemplate.Execute(buf, data); err != nil {
+		return nil, "", err
+	}
+	formatted, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, "", err
+	}
+	source[main] = map[string]string{"main.go": string(formatted)}
+
+	return source, main, nil
+}
+
+// testFileName renames a test file so it's no longer excluded from the build. The file name
+// still ends with any GOOS / GOARCH suffix so the build constraints are unchanged.
+func testFileName(name string) string {
+	return "jsgotest_" + strings.TrimSuffix(name, "_test.go") + ".go"
+}
+
+type testMainData struct {
+	Run      string
+	Verbose  bool
+	Internal *testPackage
+	External *testPackage
+}
+
+// TestMain returns the package with the TestMain function, or nil.
+func (d testMainData) TestMain() *testPackage {
+	for _, p := range d.Packages() {
+		if p.TestMain {
+			return p
+		}
+	}
+	return nil
+}
+
+func (d testMainData) Packages() []*testPackage {
+	var packages []*testPackage
+	for _, p := range []*testPackage{d.Internal, d.External} {
+		if p != nil {
+			packages = append(packages, p)
+		}
+	}
+	return packages
+}
+
+type testPackage struct {
+	Path       string
+	Name       string // Name used to import the package in the test main
+	Tests      []string
+	Benchmarks []string
+	Examples   []*doc.Example
+	TestMain   bool
+}
+
+// Used returns true if the test main refers to the package, so it's not imported with a blank name.
+func (p *testPackage) Used() bool {
+	return p.TestMain || len(p.Tests) > 0 || len(p.Benchmarks) > 0 || len(p.Examples) > 0
+}
+
+func (p *testPackage) load(name string, files []*ast.File) {
+	p.Name = name
+	for _, f := range files {
+		for _, d := range f.Decls {
+			fn, ok := d.(*ast.FuncDecl)
+			if !ok || fn.Recv != nil {
+				continue
+			}
+			switch {
+			case fn.Name.Name == "TestMain" && hasTestingParam(fn, "M"):
+				p.TestMain = true
+			case isTest(fn.Name.Name, "Test") && hasTestingParam(fn, "T"):
+				p.Tests = append(p.Tests, fn.Name.Name)
+			case isTest(fn.Name.Name, "Benchmark") && hasTestingParam(fn, "B"):
+				p.Benchmarks = append(p.Benchmarks, fn.Name.Name)
+			}
+		}
+	}
+	for _, e := range doc.Examples(files...) {
+		// Examples without an output comment are compiled but not run.
+		if e.Output == "" && !e.EmptyOutput {
+			continue
+		}
+		p.Examples = append(p.Examples, e)
+	}
+}
+
+// isTest is the same check as "go test": Test, Benchmark and Example must not be followed by a lower
+// case letter.
+func isTest(name, prefix string) bool {
+	if !strings.HasPrefix(name, prefix) {
+		return false
+	}
+	if len(name) == len(prefix) {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(name[len(prefix):])
+	return !unicode.IsLower(r)
+}
+
+// hasTestingParam returns true if the function has a single parameter of type *testing.<typ>.
+func hasTestingParam(fn *ast.FuncDecl, typ string) bool {
+	if fn.Type.Results != nil && len(fn.Type.Results.List) > 0 {
+		return false
+	}
+	params := fn.Type.Params.List
+	if len(params) != 1 || len(params[0].Names) > 1 {
+		return false
+	}
+	star, ok := params[0].Type.(*ast.StarExpr)
+	if !ok {
+		return false
+	}
+	sel, ok := star.X.(*ast.SelectorExpr)
+	return ok && sel.Sel.Name == typ
+}
+
+// testMainTemplate is the generated test main, for the testing package in the GopherJS standard library.
+// testing/internal/testdeps can't be imported from here, so testDeps is implemented in the template.
+var testMainTemplate = template.Must(template.New("main").Parse(`package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	{{- if not .TestMain }}
+	"os"
+	{{- end }}
+	"regexp"
+	"testing"
+{{ range .Packages }}
+	{{ if .Used }}{{ .Name }}{{ else }}_{{ end }} {{ printf "%q" .Path }}{{ end }}
+)
+
+var tests = []testing.InternalTest{
+{{- range $p := .Packages }}{{ range .Tests }}
+	{ {{ printf "%q" . }}, {{ $p.Name }}.{{ . }} },{{ end }}{{ end }}
+}
+
+var benchmarks = []testing.InternalBenchmark{
+{{- range $p := .Packages }}{{ range .Benchmarks }}
+	{ {{ printf "%q" . }}, {{ $p.Name }}.{{ . }} },{{ end }}{{ end }}
+}
+
+var examples = []testing.InternalExample{
+{{- range $p := .Packages }}{{ range .Examples }}
+	{ Name: {{ printf "%q" (print "Example" .Name) }}, F: {{ $p.Name }}.Example{{ .Name }}, Output: {{ printf "%q" .Output }}, Unordered: {{ .Unordered }} },{{ end }}{{ end }}
+}
+
+type testDeps struct{}
+
+var matchPat string
+var matchRe *regexp.Regexp
+
+func (testDeps) MatchString(pat, str string) (bool, error) {
+	if matchRe == nil || matchPat != pat {
+		re, err := regexp.Compile(pat)
+		if err != nil {
+			return false, err
+		}
+		matchPat, matchRe = pat, re
+	}
+	return matchRe.MatchString(str), nil
+}
+
+func (testDeps) StartCPUProfile(io.Writer) error { return errors.New("profiling not supported") }
+func (testDeps) StopCPUProfile()                 {}
+func (testDeps) WriteHeapProfile(io.Writer) error { return errors.New("profiling not supported") }
+func (testDeps) WriteProfileTo(string, io.Writer, int) error {
+	return errors.New("profiling not supported")
+}
+func (testDeps) ImportPath() string        { return {{ printf "%q" (index .Packages 0).Path }} }
+func (testDeps) StartTestLog(io.Writer)    {}
+func (testDeps) StopTestLog() error        { return nil }
+func (testDeps) SetPanicOnExit0(bool)      {}
+
+func main() {
+	m := testing.MainStart(testDeps{}, tests, benchmarks, examples)
+	flag.Set("test.v", "{{ .Verbose }}")
+{{- if .Run }}
+	flag.Set("test.run", {{ printf "%q" .Run }})
+{{- end }}
+{{- with .TestMain }}
+	{{ .Name }}.TestMain(m)
+{{- else }}
+	os.Exit(m.Run())
+{{- end }}
+}
+`))
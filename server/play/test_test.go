@@ -0,0 +1,201 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package play
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/play/messages"
+)
+
+func TestGetTestSource(t *testing.T) {
+	type spec struct {
+		source   map[string]string // Files in package "a"
+		run      string
+		expected []string // Must be in the generated main.go
+		err      string
+	}
+	tests := map[string]spec{
+		"internal only": {
+			source: map[string]string{
+				"a.go":      "package a\n\nfunc F() int { return 1 }\n",
+				"a_test.go": "package a\n\nimport \"testing\"\n\nfunc TestF(t *testing.T) { F() }\n\nfunc BenchmarkF(b *testing.B) {}\n\nfunc Testify(t *testing.T) {}\n",
+			},
+			expected: []string{
+				`_test "a"`,
+				`{"TestF", _test.TestF}`,
+				`{"BenchmarkF", _test.BenchmarkF}`,
+				`os.Exit(m.Run())`,
+			},
+		},
+		"external only": {
+			source: map[string]string{
+				"a.go":      "package a\n\nfunc F() int { return 1 }\n",
+				"a_test.go": "package a_test\n\nimport (\n\t\"a\"\n\t\"fmt\"\n\t\"testing\"\n)\n\nfunc TestF(t *testing.T) { a.F() }\n\nfunc ExampleF() {\n\tfmt.Println(a.F())\n\t// Output: 1\n}\n\nfunc ExampleNoOutput() {\n\tfmt.Println(a.F())\n}\n",
+			},
+			run: "TestF",
+			expected: []string{
+				`_xtest "a_test"`,
+				`{"TestF", _xtest.TestF}`,
+				`F: _xtest.ExampleF, Output: "1\n"`,
+				`flag.Set("test.run", "TestF")`,
+			},
+		},
+		"internal TestMain": {
+			source: map[string]string{
+				"a.go":       "package a\n\nfunc F() int { return 1 }\n",
+				"a_test.go":  "package a\n\nimport (\n\t\"os\"\n\t\"testing\"\n)\n\nfunc TestMain(m *testing.M) { os.Exit(m.Run()) }\n",
+				"ax_test.go": "package a_test\n\nimport \"a\"\n\nvar _ = a.F\n",
+			},
+			expected: []string{
+				`_test "a"`,
+				`_ "a_test"`,
+				`_test.TestMain(m)`,
+			},
+		},
+		"external TestMain": {
+			source: map[string]string{
+				"a.go":      "package a\n",
+				"a_test.go": "package a_test\n\nimport \"testing\"\n\nfunc TestMain(m *testing.M) { m.Run() }\n\nfunc TestF(t *testing.T) {}\n",
+			},
+			expected: []string{
+				`{"TestF", _xtest.TestF}`,
+				`_xtest.TestMain(m)`,
+			},
+		},
+		"multiple TestMain": {
+			source: map[string]string{
+				"a.go":       "package a\n",
+				"a_test.go":  "package a\n\nimport \"testing\"\n\nfunc TestMain(m *testing.M) {}\n",
+				"ax_test.go": "package a_test\n\nimport \"testing\"\n\nfunc TestMain(m *testing.M) {}\n",
+			},
+			err: "multiple definitions of TestMain in a",
+		},
+		"no tests": {
+			source: map[string]string{"a.go": "package a\n"},
+			err:    "no test files in a",
+		},
+	}
+	for name, test := range tests {
+		info := messages.Test{Path: "a", Source: map[string]map[string]string{"a": test.source}, Run: test.run}
+		source, main, err := getTestSource(info)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%s: expected error %q, got %v", name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if main != "a.test" {
+			t.Errorf("%s: expected main package a.test, got %s", name, main)
+		}
+		contents := source[main]["main.go"]
+		for _, s := range test.expected {
+			if !strings.Contains(contents, s) {
+				t.Errorf("%s: main.go doesn't contain %q:\n%s", name, s, contents)
+			}
+		}
+		fset := token.NewFileSet()
+		imp := &testImporter{
+			fset:     fset,
+			std:      importer.ForCompiler(fset, "source", nil),
+			source:   source,
+			packages: map[string]*types.Package{},
+		}
+		if _, err := imp.Import(main); err != nil {
+			t.Errorf("%s: %v\n%s", name, err, contents)
+		}
+	}
+}
+
+// testImporter type checks the packages in source. The standard library is imported from the host,
+// apart from testing, which is replaced by the API of the GopherJS testing package.
+type testImporter struct {
+	fset     *token.FileSet
+	std      types.Importer
+	source   map[string]map[string]string
+	packages map[string]*types.Package
+}
+
+func (i *testImporter) Import(path string) (*types.Package, error) {
+	if p, ok := i.packages[path]; ok {
+		return p, nil
+	}
+	files, ok := i.source[path]
+	if path == "testing" {
+		files, ok = map[string]string{"testing.go": testingStub}, true
+	}
+	if !ok {
+		return i.std.Import(path)
+	}
+	var parsed []*ast.File
+	for name, contents := range files {
+		f, err := parser.ParseFile(i.fset, name, contents, 0)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, f)
+	}
+	conf := types.Config{Importer: i}
+	p, err := conf.Check(path, i.fset, parsed, nil)
+	if err != nil {
+		return nil, err
+	}
+	i.packages[path] = p
+	return p, nil
+}
+
+const testingStub = `package testing
+
+import "io"
+
+type T struct{}
+type B struct{}
+type M struct{}
+
+func (m *M) Run() int { return 0 }
+
+type InternalTest struct {
+	Name string
+	F    func(*T)
+}
+
+type InternalBenchmark struct {
+	Name string
+	F    func(*B)
+}
+
+type InternalExample struct {
+	Name      string
+	F         func()
+	Output    string
+	Unordered bool
+}
+
+type testDeps interface {
+	MatchString(pat, str string) (bool, error)
+	StartCPUProfile(io.Writer) error
+	StopCPUProfile()
+	WriteHeapProfile(io.Writer) error
+	WriteProfileTo(string, io.Writer, int) error
+	ImportPath() string
+	StartTestLog(io.Writer)
+	StopTestLog() error
+}
+
+func MainStart(deps testDeps, tests []InternalTest, benchmarks []InternalBenchmark, examples []InternalExample) *M {
+	return &M{}
+}
+`
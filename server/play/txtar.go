@@ -0,0 +1,175 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package play
+
+import (
+	"context"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dave/services"
+	"golang.org/x/tools/txtar"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/play/messages"
+)
+
+// Txtar converts the source to the txtar format used by play.golang.org for multi file snippets, in
+// response to the Export message.
+func (h *Handler) Txtar(ctx context.Context, info messages.Export, req *http.Request, send func(message services.Message), receive chan services.Message) error {
+	b, err := sourceToTxtar(info.Source)
+	if err != nil {
+		return err
+	}
+	send(messages.ExportComplete{Txtar: string(b)})
+	return nil
+}
+
+// parseTxtar converts a play.golang.org snippet to source packages. Snippets with a single file are
+// plain Go source, and multi file snippets are txtar archives where leading content is prog.go. Files
+// in directories are placed in packages relative to the module path in go.mod, or relative to path if
+// there's no go.mod. The module path must not shadow other packages (see checkModule).
+func parseTxtar(root string, b []byte) (map[string]map[string]string, error) {
+	a := txtar.Parse(b)
+	if len(a.Files) == 0 {
+		return map[string]map[string]string{root: {"main.go": string(b)}}, nil
+	}
+	if strings.TrimSpace(string(a.Comment)) != "" {
+		a.Files = append([]txtar.File{{Name: "prog.go", Data: a.Comment}}, a.Files...)
+	}
+
+	var module string
+	for _, f := range a.Files {
+		if f.Name != "go.mod" {
+			continue
+		}
+		for _, line := range strings.Split(string(f.Data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 2 && fields[0] == "module" {
+				module = strings.Trim(fields[1], `"`)
+				break
+			}
+		}
+	}
+	if module != "" {
+		root = module
+	}
+
+	source := map[string]map[string]string{}
+	for _, f := range a.Files {
+		name := path.Clean(f.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return nil, fmt.Errorf("invalid file name %q", f.Name)
+		}
+		dir, file := path.Split(name)
+		if !isValidFile(file) {
+			// go.mod, go.sum etc.
+			continue
+		}
+		pkg := root
+		if dir != "" {
+			pkg = root + "/" + strings.TrimSuffix(dir, "/")
+		}
+		if source[pkg] == nil {
+			source[pkg] = map[string]string{}
+		}
+		if _, ok := source[pkg][file]; ok {
+			return nil, fmt.Errorf("duplicate file %q", f.Name)
+		}
+		source[pkg][file] = string(f.Data)
+	}
+	if module != "" {
+		if err := checkModule(module, source); err != nil {
+			return nil, err
+		}
+	}
+	return source, nil
+}
+
+// checkModule returns an error if the module path of a snippet would shadow packages that aren't in the
+// snippet. Like the go command, the first element of the path must contain a dot, so it can't be a
+// standard library package, and every package imported from below the module path must be in source.
+func checkModule(module string, source map[string]map[string]string) error {
+	first := strings.Split(module, "/")[0]
+	if !strings.Contains(first, ".") || strings.HasPrefix(first, ".") || path.Clean(module) != module || strings.ContainsAny(module, "\\:@") {
+		return fmt.Errorf("invalid module path %q", module)
+	}
+	for _, files := range source {
+		for name, contents := range files {
+			if !strings.HasSuffix(name, ".go") {
+				continue
+			}
+			f, _ := parser.ParseFile(token.NewFileSet(), name, contents, parser.ImportsOnly)
+			if f == nil {
+				continue
+			}
+			for _, spec := range f.Imports {
+				ipath, err := strconv.Unquote(spec.Path.Value)
+				if err != nil {
+					continue
+				}
+				if (ipath == module || strings.HasPrefix(ipath, module+"/")) && source[ipath] == nil {
+					return fmt.Errorf("module %s conflicts with imported package %s", module, ipath)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// sourceToTxtar converts source packages to a txtar archive. Packages are placed in directories
+// relative to the longest common path, and a go.mod is added for multiple packages so the import paths
+// still resolve.
+func sourceToTxtar(source map[string]map[string]string) ([]byte, error) {
+	if len(source) == 0 {
+		return nil, fmt.Errorf("no source")
+	}
+	var paths []string
+	for p := range source {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	root := strings.Split(paths[0], "/")
+	for _, p := range paths[1:] {
+		parts := strings.Split(p, "/")
+		i := 0
+		for i < len(root) && i < len(parts) && root[i] == parts[i] {
+			i++
+		}
+		root = root[:i]
+	}
+	module := strings.Join(root, "/")
+
+	a := &txtar.Archive{}
+	if len(paths) > 1 {
+		if module == "" {
+			return nil, fmt.Errorf("packages have no common path")
+		}
+		a.Files = append(a.Files, txtar.File{Name: "go.mod", Data: []byte(fmt.Sprintf("module %s\n", module))})
+	}
+	for _, p := range paths {
+		dir := strings.TrimPrefix(strings.TrimPrefix(p, module), "/")
+		var names []string
+		for name := range source[p] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			data := source[p][name]
+			if data != "" && !strings.HasSuffix(data, "\n") {
+				data += "\n"
+			}
+			a.Files = append(a.Files, txtar.File{Name: path.Join(dir, name), Data: []byte(data)})
+		}
+	}
+	return txtar.Format(a), nil
+}
@@ -0,0 +1,185 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package play
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTxtar(t *testing.T) {
+	type spec struct {
+		root     string
+		txtar    string
+		expected map[string]map[string]string
+		err      string
+	}
+	tests := map[string]spec{
+		"single file": {
+			root:     "a",
+			txtar:    "package main\n",
+			expected: map[string]map[string]string{"a": {"main.go": "package main\n"}},
+		},
+		"leading comment is prog.go": {
+			root:  "a",
+			txtar: "package main\n-- b.go --\npackage main\n",
+			expected: map[string]map[string]string{
+				"a": {"prog.go": "package main\n", "b.go": "package main\n"},
+			},
+		},
+		"blank leading comment": {
+			root:     "a",
+			txtar:    "\n\n-- b.go --\npackage main\n",
+			expected: map[string]map[string]string{"a": {"b.go": "package main\n"}},
+		},
+		"directories relative to root": {
+			root:  "a",
+			txtar: "-- main.go --\npackage main\n-- b/b.go --\npackage b\n",
+			expected: map[string]map[string]string{
+				"a":   {"main.go": "package main\n"},
+				"a/b": {"b.go": "package b\n"},
+			},
+		},
+		"go.mod module is root": {
+			root:  "a",
+			txtar: "-- go.mod --\nmodule \"c.com/d\"\n-- main.go --\npackage main\n-- b/b.go --\npackage b\n",
+			expected: map[string]map[string]string{
+				"c.com/d":   {"main.go": "package main\n"},
+				"c.com/d/b": {"b.go": "package b\n"},
+			},
+		},
+		"std module path": {
+			root:  "a",
+			txtar: "-- go.mod --\nmodule fmt\n-- main.go --\npackage fmt\n",
+			err:   `invalid module path "fmt"`,
+		},
+		"module shadows imported package": {
+			root:  "a",
+			txtar: "-- go.mod --\nmodule c.com/d\n-- main.go --\npackage main\n\nimport \"c.com/d/e\"\n",
+			err:   "module c.com/d conflicts with imported package c.com/d/e",
+		},
+		"imported package in snippet": {
+			root:  "a",
+			txtar: "-- go.mod --\nmodule c.com/d\n-- main.go --\npackage main\n\nimport \"c.com/d/b\"\n-- b/b.go --\npackage b\n",
+			expected: map[string]map[string]string{
+				"c.com/d":   {"main.go": "package main\n\nimport \"c.com/d/b\"\n"},
+				"c.com/d/b": {"b.go": "package b\n"},
+			},
+		},
+		"invalid files skipped": {
+			root:     "a",
+			txtar:    "-- main.go --\npackage main\n-- go.sum --\nfoo\n-- b.txt --\nbar\n",
+			expected: map[string]map[string]string{"a": {"main.go": "package main\n"}},
+		},
+		"parent dir": {
+			root:  "a",
+			txtar: "-- ../main.go --\npackage main\n",
+			err:   `invalid file name "../main.go"`,
+		},
+		"parent dir after clean": {
+			root:  "a",
+			txtar: "-- b/../../main.go --\npackage main\n",
+			err:   `invalid file name "b/../../main.go"`,
+		},
+		"absolute": {
+			root:  "a",
+			txtar: "-- /main.go --\npackage main\n",
+			err:   `invalid file name "/main.go"`,
+		},
+		"duplicate": {
+			root:  "a",
+			txtar: "-- main.go --\npackage main\n-- ./main.go --\npackage main\n",
+			err:   `duplicate file "./main.go"`,
+		},
+	}
+	for name, test := range tests {
+		source, err := parseTxtar(test.root, []byte(test.txtar))
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%s: expected error %q, got %v", name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(source, test.expected) {
+			t.Errorf("%s: expected %v, got %v", name, test.expected, source)
+		}
+	}
+}
+
+func TestSourceToTxtar(t *testing.T) {
+	type spec struct {
+		source   map[string]map[string]string
+		expected string
+		err      string
+	}
+	tests := map[string]spec{
+		"single package": {
+			source:   map[string]map[string]string{"a": {"main.go": "package main"}},
+			expected: "-- main.go --\npackage main\n",
+		},
+		"files sorted": {
+			source:   map[string]map[string]string{"a": {"b.go": "package main\n", "a.go": "package main\n"}},
+			expected: "-- a.go --\npackage main\n-- b.go --\npackage main\n",
+		},
+		"common prefix": {
+			source: map[string]map[string]string{
+				"c.com/d":   {"main.go": "package main\n"},
+				"c.com/d/b": {"b.go": "package b\n"},
+			},
+			expected: "-- go.mod --\nmodule c.com/d\n-- main.go --\npackage main\n-- b/b.go --\npackage b\n",
+		},
+		"common prefix is whole elements": {
+			source: map[string]map[string]string{
+				"c.com/foo":    {"main.go": "package main\n"},
+				"c.com/foobar": {"b.go": "package foobar\n"},
+			},
+			expected: "-- go.mod --\nmodule c.com\n-- foo/main.go --\npackage main\n-- foobar/b.go --\npackage foobar\n",
+		},
+		"no common path": {
+			source: map[string]map[string]string{
+				"a": {"main.go": "package main\n"},
+				"b": {"b.go": "package b\n"},
+			},
+			err: "packages have no common path",
+		},
+		"no source": {
+			source: map[string]map[string]string{},
+			err:    "no source",
+		},
+	}
+	for name, test := range tests {
+		b, err := sourceToTxtar(test.source)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%s: expected error %q, got %v", name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if string(b) != test.expected {
+			t.Errorf("%s: expected %q, got %q", name, test.expected, string(b))
+			continue
+		}
+		// Converting back gives the same packages when there's a go.mod.
+		if len(test.source) > 1 {
+			source, err := parseTxtar("", b)
+			if err != nil {
+				t.Errorf("%s: %v", name, err)
+				continue
+			}
+			if !reflect.DeepEqual(source, test.source) {
+				t.Errorf("%s: round trip expected %v, got %v", name, test.source, source)
+			}
+		}
+	}
+}
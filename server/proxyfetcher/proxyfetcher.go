@@ -0,0 +1,287 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+// Package proxyfetcher gets module zips using the GOPROXY protocol, as an alternative to cloning git
+// repos. This also works for modules hosted with other version control systems (hg, svn, bzr), and
+// large repos that exceed the git object limit.
+package proxyfetcher
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shurcooL/go/ctxhttp"
+	"golang.org/x/mod/semver"
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+)
+
+type Config struct {
+	Timeout     time.Duration // Timeout for each request to the proxy
+	MaxZipLen   int64         // Maximum size of a module zip
+	MaxFilesLen int64         // Maximum total size of the files in a module zip, after decompression
+	VersionTime time.Duration // Time the latest version of a module is cached before asking the proxy again
+}
+
+// New returns a Fetcher for the proxy at url. Proxies may be served over http(s), or from the local
+// filesystem with a file:// url.
+func New(url string, config Config) *Fetcher {
+	return &Fetcher{
+		url:      strings.TrimSuffix(url, "/"),
+		config:   config,
+		client:   &http.Client{Timeout: config.Timeout},
+		versions: map[string]versionEntry{},
+	}
+}
+
+type Fetcher struct {
+	url    string
+	config Config
+	client *http.Client
+
+	m        sync.Mutex
+	versions map[string]versionEntry // module -> resolved version
+}
+
+type versionEntry struct {
+	version string
+	expires time.Time
+}
+
+// Resolve finds the module that contains the package at path, and returns the module path as the root.
+// The root is also used as the url passed to Fetch.
+func (f *Fetcher) Resolve(ctx context.Context, path string) (root, url string, err error) {
+	parts := strings.Split(path, "/")
+	for i := len(parts); i > 0; i-- {
+		module := strings.Join(parts[:i], "/")
+		version, err := f.version(ctx, module)
+		if err == errNotFound {
+			continue
+		}
+		if err != nil {
+			return "", "", err
+		}
+		if err := f.checkMod(ctx, module, version); err != nil {
+			return "", "", err
+		}
+		return module, module, nil
+	}
+	return "", "", fmt.Errorf("no module found for %s", path)
+}
+
+// Fetch gets the zip for the latest version of the module, and returns the contents.
+func (f *Fetcher) Fetch(ctx context.Context, repo string) (billy.Filesystem, error) {
+	module := moduleFromUrl(repo)
+	version, err := f.version(ctx, module)
+	if err != nil {
+		return nil, err
+	}
+	b, err := f.get(ctx, fmt.Sprintf("%s/@v/%s.zip", escape(module), escape(version)))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > f.config.MaxZipLen {
+		return nil, fmt.Errorf("module %s@%s zip is too large", module, version)
+	}
+	return unzip(b, fmt.Sprintf("%s@%s/", module, version), f.config.MaxFilesLen)
+}
+
+// version gets the latest version of module. Releases are preferred to pre-releases, and if no
+// versions are listed the proxy is asked for @latest (e.g. pseudo-versions).
+func (f *Fetcher) version(ctx context.Context, module string) (string, error) {
+	f.m.Lock()
+	e, ok := f.versions[module]
+	f.m.Unlock()
+	if ok && time.Now().Before(e.expires) {
+		return e.version, nil
+	}
+
+	var version string
+
+	b, err := f.get(ctx, escape(module)+"/@v/list")
+	if err != nil {
+		return "", err
+	}
+	var release, prerelease string
+	for _, v := range strings.Fields(string(b)) {
+		if !semver.IsValid(v) {
+			continue
+		}
+		if semver.Prerelease(v) == "" {
+			if release == "" || semver.Compare(v, release) > 0 {
+				release = v
+			}
+		} else {
+			if prerelease == "" || semver.Compare(v, prerelease) > 0 {
+				prerelease = v
+			}
+		}
+	}
+	switch {
+	case release != "":
+		version = release
+	case prerelease != "":
+		version = prerelease
+	default:
+		b, err := f.get(ctx, escape(module)+"/@latest")
+		if err != nil {
+			return "", err
+		}
+		var info struct{ Version string }
+		if err := json.Unmarshal(b, &info); err != nil {
+			return "", err
+		}
+		if info.Version == "" {
+			return "", errNotFound
+		}
+		version = info.Version
+	}
+
+	// check the version exists
+	if _, err := f.get(ctx, fmt.Sprintf("%s/@v/%s.info", escape(module), escape(version))); err != nil {
+		return "", err
+	}
+
+	f.m.Lock()
+	f.versions[module] = versionEntry{version: version, expires: time.Now().Add(f.config.VersionTime)}
+	f.m.Unlock()
+
+	return version, nil
+}
+
+// checkMod checks the module path in the go.mod file matches the module we asked for.
+func (f *Fetcher) checkMod(ctx context.Context, module, version string) error {
+	b, err := f.get(ctx, fmt.Sprintf("%s/@v/%s.mod", escape(module), escape(version)))
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "module" {
+			if declared := strings.Trim(fields[1], `"`); declared != module {
+				return fmt.Errorf("module %s declares its path as %s", module, declared)
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+var errNotFound = errors.New("not found")
+
+func (f *Fetcher) get(ctx context.Context, name string) ([]byte, error) {
+	if strings.HasPrefix(f.url, "file://") {
+		b, err := ioutil.ReadFile(filepath.Join(filepath.FromSlash(strings.TrimPrefix(f.url, "file://")), filepath.FromSlash(name)))
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, errNotFound
+			}
+			return nil, err
+		}
+		return b, nil
+	}
+	resp, err := ctxhttp.Get(ctx, f.client, f.url+"/"+name)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == 404 || resp.StatusCode == 410:
+		return nil, errNotFound
+	case resp.StatusCode != 200:
+		return nil, fmt.Errorf("error %d getting %s from proxy", resp.StatusCode, name)
+	}
+	return ioutil.ReadAll(io.LimitReader(resp.Body, f.config.MaxZipLen+1))
+}
+
+// unzip extracts the files in a module zip below prefix. The total size of the files is limited to max
+// bytes, whatever the sizes in the zip headers, and files outside prefix are rejected.
+func unzip(b []byte, prefix string, max int64) (billy.Filesystem, error) {
+	r, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, err
+	}
+	fs := memfs.New()
+	remaining := max
+	for _, zf := range r.File {
+		if !strings.HasPrefix(zf.Name, prefix) {
+			return nil, fmt.Errorf("unexpected file %s in module zip", zf.Name)
+		}
+		if strings.HasSuffix(zf.Name, "/") {
+			continue
+		}
+		name := strings.TrimPrefix(zf.Name, prefix)
+		if name == "" || path.IsAbs(name) || path.Clean(name) != name || name == ".." || strings.HasPrefix(name, "../") || strings.Contains(name, "\\") {
+			return nil, fmt.Errorf("invalid file %s in module zip", zf.Name)
+		}
+		if zf.UncompressedSize64 > uint64(remaining) {
+			return nil, fmt.Errorf("module %s zip is too large", strings.TrimSuffix(prefix, "/"))
+		}
+		err := func() error {
+			zr, err := zf.Open()
+			if err != nil {
+				return err
+			}
+			defer zr.Close()
+			f, err := fs.Create(name)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			// The header may understate the size, so the copy is limited too.
+			n, err := io.Copy(f, io.LimitReader(zr, remaining+1))
+			if err != nil {
+				return err
+			}
+			remaining -= n
+			if remaining < 0 {
+				return fmt.Errorf("module %s zip is too large", strings.TrimSuffix(prefix, "/"))
+			}
+			return nil
+		}()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return fs, nil
+}
+
+// moduleFromUrl converts the url of a repo (e.g. https://github.com/foo/bar.git) to a module path.
+// Module paths returned by Resolve are unchanged.
+func moduleFromUrl(repo string) string {
+	if u, err := url.Parse(repo); err == nil && u.Host != "" {
+		repo = u.Host + u.Path
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(repo, "/"), ".git")
+}
+
+// escape encodes upper case letters as ! followed by the lower case letter, as specified by the
+// GOPROXY protocol.
+func escape(s string) string {
+	buf := &bytes.Buffer{}
+	for _, r := range s {
+		if 'A' <= r && r <= 'Z' {
+			buf.WriteByte('!')
+			buf.WriteRune(r + 'a' - 'A')
+			continue
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
@@ -0,0 +1,159 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package proxyfetcher
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFetch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "proxy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	module := "example.com/Foo/bar"
+	files := map[string]string{
+		"go.mod":      "module example.com/Foo/bar\n",
+		"bar.go":      "package bar\n",
+		"baz/baz.go":  "package baz\n",
+		"README.md":   "bar\n",
+		"qux/qux.go":  "package qux\n",
+		"qux/qux.txt": "qux\n",
+	}
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for name, contents := range files {
+		w, err := zw.Create(module + "@v1.1.0/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(contents))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	proxy := map[string]string{
+		"example.com/!foo/bar/@v/list":        "v1.0.0\nv1.1.0\nv1.2.0-beta\n",
+		"example.com/!foo/bar/@v/v1.1.0.info": `{"Version":"v1.1.0","Time":"2018-01-01T00:00:00Z"}`,
+		"example.com/!foo/bar/@v/v1.1.0.mod":  files["go.mod"],
+		"example.com/!foo/bar/@v/v1.1.0.zip":  buf.String(),
+	}
+	for name, contents := range proxy {
+		fpath := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fpath), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fpath, []byte(contents), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f := New("file://"+filepath.ToSlash(dir), Config{Timeout: time.Second, MaxZipLen: 1024 * 1024, MaxFilesLen: 1024 * 1024})
+	ctx := context.Background()
+
+	root, url, err := f.Resolve(ctx, "example.com/Foo/bar/baz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root != module {
+		t.Fatalf("unexpected root %q", root)
+	}
+
+	fs, err := f.Fetch(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, expected := range files {
+		file, err := fs.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(file)
+		file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != expected {
+			t.Fatalf("unexpected contents of %s: %q", name, string(b))
+		}
+	}
+
+	if _, _, err := f.Resolve(ctx, "example.com/other"); err == nil {
+		t.Fatal("expected error resolving missing module")
+	}
+}
+
+func TestUnzip(t *testing.T) {
+	prefix := "example.com/foo@v1.0.0/"
+	tests := map[string]struct {
+		files map[string]string
+		err   string
+	}{
+		"valid": {
+			files: map[string]string{"foo.go": "package foo\n", "bar/bar.go": "package bar\n"},
+		},
+		"parent dir": {
+			files: map[string]string{"../foo.go": "package foo\n"},
+			err:   "invalid file example.com/foo@v1.0.0/../foo.go in module zip",
+		},
+		"parent dir inside": {
+			files: map[string]string{"bar/../../foo.go": "package foo\n"},
+			err:   "invalid file example.com/foo@v1.0.0/bar/../../foo.go in module zip",
+		},
+		"too large": {
+			files: map[string]string{"foo.go": strings.Repeat("a", 600), "bar.go": strings.Repeat("b", 600)},
+			err:   "module example.com/foo@v1.0.0 zip is too large",
+		},
+	}
+	for name, test := range tests {
+		buf := &bytes.Buffer{}
+		zw := zip.NewWriter(buf)
+		for fname, contents := range test.files {
+			w, err := zw.Create(prefix + fname)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.Write([]byte(contents))
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		_, err := unzip(buf.Bytes(), prefix, 1000)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%s: expected error %q, got %v", name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestEscape(t *testing.T) {
+	tests := map[string]string{
+		"github.com/foo/bar":         "github.com/foo/bar",
+		"github.com/Azure/azure-sdk": "github.com/!azure/azure-sdk",
+		"github.com/BurntSushi/TOML": "github.com/!burnt!sushi/!t!o!m!l",
+	}
+	for in, expected := range tests {
+		if out := escape(in); out != expected {
+			t.Errorf("escape(%q) = %q, expected %q", in, out, expected)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dave/services"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/assets/std"
+	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/jsgo"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/jsgo/messages"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/servermsg"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/store"
+)
+
+// Rebuild is the background job that recompiles stored packages that reference outdated standard
+// library hashes, e.g. after initialise has regenerated std.Index and std.Prelude.
+type Rebuild struct {
+	m      sync.Mutex
+	status RebuildStatus
+}
+
+// RebuildStatus is the progress of the rebuild job, shown on the admin page.
+type RebuildStatus struct {
+	Running  bool
+	Started  time.Time
+	Finished time.Time
+	Current  string // Package being rebuilt
+	Total    int    // Stored packages
+	Checked  int    // Packages checked
+	Outdated int    // Packages found with outdated standard library hashes
+	Rebuilt  int
+	Failed   int
+	Errors   []string
+}
+
+func (r *Rebuild) Status() RebuildStatus {
+	r.m.Lock()
+	defer r.m.Unlock()
+	status := r.status
+	status.Errors = append([]string(nil), r.status.Errors...)
+	return status
+}
+
+func (r *Rebuild) update(f func(s *RebuildStatus)) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	f(&r.status)
+}
+
+// StartRebuild starts the rebuild job in the background.
+func (h *Handler) StartRebuild() error {
+	if h.Lister == nil {
+		return errors.New("the database can't list packages, so rebuild is not available")
+	}
+	h.Rebuild.m.Lock()
+	defer h.Rebuild.m.Unlock()
+	if h.Rebuild.status.Running {
+		return errors.New("rebuild already running")
+	}
+	h.Rebuild.status = RebuildStatus{Running: true, Started: time.Now()}
+	h.Waitgroup.Add(1)
+	go func() {
+		defer h.Waitgroup.Done()
+		err := h.rebuild()
+		h.Rebuild.update(func(s *RebuildStatus) {
+			if err != nil {
+				s.Errors = append(s.Errors, err.Error())
+			}
+			s.Running = false
+			s.Current = ""
+			s.Finished = time.Now()
+		})
+	}()
+	return nil
+}
+
+func (h *Handler) rebuild() error {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Stop when the server shuts down
+	go func() {
+		select {
+		case <-h.shutdown:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	paths, err := h.Lister.ListPackages(ctx)
+	if err != nil {
+		return err
+	}
+	h.Rebuild.update(func(s *RebuildStatus) { s.Total = len(paths) })
+
+	var last time.Time
+	for _, path := range paths {
+		found, data, err := store.Package(ctx, h.Database, path)
+		if err != nil {
+			return err
+		}
+		h.Rebuild.update(func(s *RebuildStatus) { s.Checked++ })
+		if !found || !outdated(data) {
+			continue
+		}
+		h.Rebuild.update(func(s *RebuildStatus) {
+			s.Outdated++
+			s.Current = path
+		})
+
+		// Limit the rate so the rebuild doesn't starve normal requests.
+		select {
+		case <-time.After(time.Until(last.Add(config.RebuildInterval))):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		last = time.Now()
+
+		if err := h.rebuildPackage(ctx, path, data); err != nil {
+			h.Rebuild.update(func(s *RebuildStatus) {
+				s.Failed++
+				s.Errors = append(s.Errors, fmt.Sprintf("%s: %v", path, err))
+			})
+			continue
+		}
+		h.Rebuild.update(func(s *RebuildStatus) { s.Rebuilt++ })
+	}
+	return nil
+}
+
+// rebuildPackage compiles the package through the normal queue, with the same options as the stored
+// compile.
+func (h *Handler) rebuildPackage(ctx context.Context, path string, data store.CompileData) error {
+
+	ctx, cancel := context.WithTimeout(ctx, config.RequestTimeout)
+	defer cancel()
+
+	start, end, err := h.Queue.Slot(func(int) {})
+	if err != nil {
+		return err
+	}
+	defer close(end)
+
+	select {
+	case <-start:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	req, err := http.NewRequest("GET", "/"+path, nil)
+	if err != nil {
+		return err
+	}
+
+	// send is called concurrently while the packages are stored.
+	var m sync.Mutex
+	var failed error
+	send := func(message services.Message) {
+		m.Lock()
+		defer m.Unlock()
+		if e, ok := message.(servermsg.Error); ok && failed == nil {
+			failed = errors.New(e.Message)
+		}
+	}
+
+	handler := &jsgo.Handler{h.Cache, h.Fileserver, h.Database, h.Credentials}
+	// Compiles stored before the options were recorded only have the bundle and module hashes.
+	info := messages.Compile{
+		Path:           path,
+		Bundle:         data.Options.Bundle || data.Min.Bundle != "",
+		Dce:            data.Options.Dce,
+		SourceMaps:     data.Options.SourceMaps,
+		SourcesContent: data.Options.SourcesContent,
+		Verify:         data.Options.Verify,
+		ServiceWorker:  data.Options.ServiceWorker,
+		Modules:        data.Options.Modules || data.Min.Module != "",
+	}
+	if err := handler.Compile(ctx, info, req, send, nil); err != nil {
+		return err
+	}
+	m.Lock()
+	defer m.Unlock()
+	return failed
+}
+
+// outdated returns true if the compile references a standard library package or prelude hash that is
+// not in the current std.Index or std.Prelude.
+func outdated(data store.CompileData) bool {
+	for min, contents := range map[bool]store.CompileContents{true: data.Min, false: data.Max} {
+		for _, p := range contents.Packages {
+			if !p.Standard {
+				continue
+			}
+			if p.Path == "prelude" {
+				if p.Hash != std.Prelude[min] {
+					return true
+				}
+				continue
+			}
+			if hashes, ok := std.Index[p.Path]; !ok || hashes[min] != p.Hash {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -40,9 +40,13 @@ import (
 
 	"github.com/sniperkit/snk.fork.dave-jsgo/assets"
 	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/credentials"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/editor"
 	"github.com/sniperkit/snk.fork.dave-jsgo/server/frizz"
 	"github.com/sniperkit/snk.fork.dave-jsgo/server/jsgo"
 	"github.com/sniperkit/snk.fork.dave-jsgo/server/play"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/proxyfetcher"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/serverconfig"
 	"github.com/sniperkit/snk.fork.dave-jsgo/server/store"
 	"github.com/sniperkit/snk.fork.dave-jsgo/server/wasm"
 )
@@ -55,6 +59,7 @@ func New(shutdown chan struct{}) *Handler {
 	var c *cache.Cache
 	var fileserver services.Fileserver
 	var database services.Database
+	var lister store.Lister
 	if config.LOCAL {
 		fileserver = localfileserver.New(config.LocalFileserverTempDir, config.Static, config.Host, config.Bucket)
 		database = localdatabase.New(config.LocalFileserverTempDir)
@@ -76,36 +81,59 @@ func New(shutdown chan struct{}) *Handler {
 			panic(err)
 		}
 
-		database = gcsdatabase.New(datastoreClient)
+		database = store.NewDatastoreDatabase(gcsdatabase.New(datastoreClient), datastoreClient)
+		lister = store.NewDatastoreLister(datastoreClient)
 		fileserver = gcsfileserver.New(storageClient, config.Buckets)
 		c = cache.New(
 			database,
 			gitfetcher.New(
 				cachefileserver.New(1024*1024*1042, 100*1024*1024),
 				fileserver,
-				config.GitFetcherConfig,
+				serverconfig.GitFetcherConfig,
 			),
 			nil,
 			config.HintsKind,
 		)
 	}
+	if proxy := os.Getenv(config.ProxyEnv); proxy != "" {
+		fetcherResolver := proxyfetcher.New(proxy, serverconfig.ProxyFetcherConfig)
+		c = cache.New(
+			database,
+			fetcherResolver,
+			fetcherResolver,
+			config.HintsKind,
+		)
+	}
 	h := &Handler{
-		mux:        http.NewServeMux(),
-		shutdown:   shutdown,
-		Queue:      queue.New(config.MaxConcurrentCompiles, config.MaxQueue),
-		Waitgroup:  &sync.WaitGroup{},
-		Cache:      c,
-		Fileserver: fileserver,
-		Database:   database,
+		mux:         http.NewServeMux(),
+		shutdown:    shutdown,
+		Queue:       queue.New(config.MaxConcurrentCompiles, config.MaxQueue),
+		Waitgroup:   &sync.WaitGroup{},
+		Cache:       c,
+		Fileserver:  fileserver,
+		Database:    database,
+		Credentials: credentials.New(database),
+		Lister:      lister,
+		Rebuild:     &Rebuild{},
+	}
+	h.Editor = editor.New(h.Cache, h.Fileserver, h.Credentials)
+	if h.Lister != nil {
+		go h.expireShares()
 	}
 	h.mux.HandleFunc("/", h.PageHandler)
 	h.mux.HandleFunc("/_script.js", h.ScriptHandler)
 	h.mux.HandleFunc("/_script.js.map", h.ScriptHandler)
 	h.mux.HandleFunc("/_info/", tracker.Handler)
+	h.mux.HandleFunc("/_export/", h.ExportHandler)
+	h.mux.HandleFunc("/_manifest/", h.ManifestHandler)
+
+	h.mux.HandleFunc("/_credentials/", h.CredentialsHandler)
+	h.mux.HandleFunc("/_admin/", h.AdminHandler)
+	h.mux.HandleFunc("/_share/", h.ShareHandler)
 
-	h.mux.HandleFunc("/_jsgo/", h.SocketHandler(&jsgo.Handler{h.Cache, h.Fileserver, h.Database}))
-	h.mux.HandleFunc("/_play/", h.SocketHandler(&play.Handler{h.Cache, h.Fileserver, h.Database}))
-	h.mux.HandleFunc("/_frizz/", h.SocketHandler(&frizz.Handler{h.Cache, h.Fileserver, h.Database}))
+	h.mux.HandleFunc("/_jsgo/", h.SocketHandler(&jsgo.Handler{h.Cache, h.Fileserver, h.Database, h.Credentials}))
+	h.mux.HandleFunc("/_play/", h.SocketHandler(&play.Handler{h.Cache, h.Fileserver, h.Database, h.Credentials, h.Editor}))
+	h.mux.HandleFunc("/_frizz/", h.SocketHandler(&frizz.Handler{h.Cache, h.Fileserver, h.Database, h.Credentials, h.Editor}))
 	h.mux.HandleFunc("/_wasm/", h.SocketHandler(&wasm.Handler{h.Cache, h.Fileserver, h.Database}))
 
 	//h.mux.HandleFunc("/_ws/", h.SocketHandler)
@@ -124,13 +152,17 @@ func New(shutdown chan struct{}) *Handler {
 }
 
 type Handler struct {
-	Cache      *cache.Cache
-	Fileserver services.Fileserver
-	Database   services.Database
-	Waitgroup  *sync.WaitGroup
-	Queue      *queue.Queue
-	mux        *http.ServeMux
-	shutdown   chan struct{}
+	Cache       *cache.Cache
+	Fileserver  services.Fileserver
+	Database    services.Database
+	Credentials *credentials.Credentials
+	Editor      *editor.Editor
+	Lister      store.Lister // Nil if the database can't list packages (e.g. in local mode)
+	Rebuild     *Rebuild
+	Waitgroup   *sync.WaitGroup
+	Queue       *queue.Queue
+	mux         *http.ServeMux
+	shutdown    chan struct{}
 }
 
 var upgrader = websocket.Upgrader{
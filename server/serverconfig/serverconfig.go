@@ -0,0 +1,41 @@
+/*
+Sniperkit-Bot
+- Status: analyzed
+*/
+
+// Package serverconfig has the configuration of the services used by the servers. It's separate from
+// package config, so the command line tool can use the hosts and constants without the server
+// dependencies.
+package serverconfig
+
+import (
+	"time"
+
+	"github.com/dave/services/deployer"
+	"github.com/dave/services/fetcher/gitfetcher"
+
+	"github.com/sniperkit/snk.fork.dave-jsgo/config"
+	"github.com/sniperkit/snk.fork.dave-jsgo/server/proxyfetcher"
+)
+
+var GitFetcherConfig = gitfetcher.Config{
+	GitSaveTimeout:  time.Second * 300,
+	GitCloneTimeout: time.Second * 300,
+	GitMaxObjects:   250000,
+	GitBucket:       config.Bucket[config.Git],
+}
+
+var ProxyFetcherConfig = proxyfetcher.Config{
+	Timeout:     time.Second * 300,
+	MaxZipLen:   500 * 1024 * 1024,
+	MaxFilesLen: 500 * 1024 * 1024,
+	VersionTime: time.Minute * 5,
+}
+
+var DeployerConfig = deployer.Config{
+	ConcurrentStorageUploads: config.ConcurrentStorageUploads,
+	IndexBucket:              config.Bucket[config.Index],
+	PkgBucket:                config.Bucket[config.Pkg],
+	PkgProtocol:              config.Protocol[config.Pkg],
+	PkgHost:                  config.Host[config.Pkg],
+}
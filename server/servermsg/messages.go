@@ -22,3 +22,51 @@ type Queueing struct {
 type Error struct {
 	Message string
 }
+
+// Diagnostic is a problem in the source found by the parser, the type checker or an analysis pass.
+type Diagnostic struct {
+	Path    string   // Package path
+	Pos     Position // Start of the problem
+	End     Position // End of the problem, or zero if it has no range
+	Source  string   // "syntax", "types" or the name of the analysis pass
+	Message string
+	Fixes   []SuggestedFix
+}
+
+// Position is a position in a source file.
+type Position struct {
+	File   string // File name
+	Offset int    // Byte offset
+	Line   int
+	Column int
+}
+
+// SuggestedFix is a set of edits that fixes the problem in a Diagnostic.
+type SuggestedFix struct {
+	Message string
+	Edits   []TextEdit
+}
+
+// TextEdit replaces the source between Pos and End with NewText.
+type TextEdit struct {
+	Pos     Position
+	End     Position
+	NewText string
+}
+
+// Candidate is an identifier that completes the code at the cursor.
+type Candidate struct {
+	Name string
+	Kind string // "var", "field", "const", "type", "func", "method", "package" or "builtin"
+	Type string // Type of the identifier
+}
+
+// HoverInfo describes the identifier under the cursor.
+type HoverInfo struct {
+	Name      string
+	Kind      string   // As Candidate.Kind
+	Signature string   // Declaration of the identifier, e.g. "func Println(a ...interface{}) (n int, err error)"
+	Doc       string   // Doc comment
+	Pos       Position // Start of the identifier under the cursor
+	End       Position // End of the identifier under the cursor
+}
@@ -7,6 +7,7 @@ package store
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"cloud.google.com/go/datastore"
@@ -29,16 +30,28 @@ type ShareData struct {
 }
 
 type CompileData struct {
-	Path string
-	Time time.Time
-	Min  CompileContents
-	Max  CompileContents
-	Ip   string
+	Path    string
+	Time    time.Time
+	Min     CompileContents
+	Max     CompileContents
+	Options CompileOptions
+	Ip      string
 
 	Success bool
 	Error   string
 }
 
+// CompileOptions are the options of a compile, so it can be repeated (e.g. by the rebuild job).
+type CompileOptions struct {
+	Bundle         bool
+	Dce            bool
+	SourceMaps     bool
+	SourcesContent bool
+	Verify         bool
+	ServiceWorker  bool
+	Modules        bool
+}
+
 type DeployData struct {
 	Time     time.Time
 	Contents DeployContents
@@ -46,21 +59,70 @@ type DeployData struct {
 	Ip       string
 }
 
+// UploadData is stored for each compiled source archive, keyed by the content hash of the source.
+type UploadData struct {
+	Time time.Time
+	Hash string
+	Main string
+	Tags []string
+	Min  DeployContents
+	Max  DeployContents
+	Ip   string
+}
+
+// ShareState is stored for each share, keyed by the hash of the share. It's used to expire, delete and
+// block shares. Shares from before this was added don't have a ShareState.
+type ShareState struct {
+	Hash        string
+	Time        time.Time
+	Expires     time.Time // Zero if the share never expires
+	Deleted     bool      // Deleted by the owner, or expired
+	Blocked     bool      // Removed by an admin. Blocked shares can't be shared again.
+	ReportCount int
+	Reports     []ShareReport
+	Ip          string
+}
+
+// ShareOwner is stored for each delete token, keyed by the hex encoded sha256 of the token. Each token
+// has its own entity, so when the same source is shared by two requests at the same time, both tokens
+// can delete the share.
+type ShareOwner struct {
+	Hash string // Hash of the share the token deletes
+	Time time.Time
+	Ip   string
+}
+
+// ShareReport is an abuse report for a share.
+type ShareReport struct {
+	Time   time.Time
+	Reason string
+	Ip     string
+}
+
 type CompileContents struct {
-	Main     string
-	Packages []CompilePackage
+	Main      string
+	Integrity string // Subresource Integrity digest of the loader
+	Bundle    string // Hash of the single file bundle, if one was created
+	Module    string // Hash of the ES module entry point, if modules were created
+	Index     string // Hash of the index page, if it's stored by hash instead of at the package path
+	Packages  []CompilePackage
 }
 
 type DeployContents struct {
-	Index    string
-	Main     string
-	Packages []CompilePackage
+	Index     string
+	Main      string
+	Integrity string // Subresource Integrity digest of the loader
+	Packages  []CompilePackage
 }
 
 type CompilePackage struct {
-	Path     string
-	Hash     string
-	Standard bool
+	Path      string
+	Hash      string
+	Standard  bool
+	Integrity string // Subresource Integrity digest of the package file
+	Size      int    // Size of the package file in bytes
+	Gzip      int    // Gzipped size of the package file in bytes
+	Module    string // Hash of the package's ES module, if modules were created
 }
 
 type WasmDeploy struct {
@@ -88,10 +150,27 @@ func StoreShare(ctx context.Context, database services.Database, data ShareData)
 	return nil
 }
 
+func StoreShareState(ctx context.Context, database services.Database, data ShareState) error {
+	if _, err := database.Put(ctx, shareStateKey(data.Hash), &data); err != nil {
+		return err
+	}
+	return nil
+}
+
+func StoreShareOwner(ctx context.Context, database services.Database, token string, data ShareOwner) error {
+	if _, err := database.Put(ctx, shareOwnerKey(token), &data); err != nil {
+		return err
+	}
+	return nil
+}
+
 func StoreDeploy(ctx context.Context, database services.Database, data DeployData) error {
 	if _, err := database.Put(ctx, deployKey(), &data); err != nil {
 		return err
 	}
+	if _, err := database.Put(ctx, deployIndexKey(data.Contents.Index), &data); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -105,6 +184,13 @@ func StoreCompile(ctx context.Context, database services.Database, path string,
 	return nil
 }
 
+func StoreUpload(ctx context.Context, database services.Database, data UploadData) error {
+	if _, err := database.Put(ctx, uploadKey(data.Hash), &data); err != nil {
+		return err
+	}
+	return nil
+}
+
 func StoreWasmDeploy(ctx context.Context, database services.Database, data WasmDeploy) error {
 	if _, err := database.Put(ctx, wasmDeployKey(), &data); err != nil {
 		return err
@@ -123,6 +209,168 @@ func Package(ctx context.Context, database services.Database, path string) (bool
 	return true, data, nil
 }
 
+func Deploy(ctx context.Context, database services.Database, index string) (bool, DeployData, error) {
+	var data DeployData
+	if err := database.Get(ctx, deployIndexKey(index), &data); err != nil {
+		if err == datastore.ErrNoSuchEntity {
+			return false, DeployData{}, nil
+		}
+		return false, DeployData{}, err
+	}
+	return true, data, nil
+}
+
+func Upload(ctx context.Context, database services.Database, hash string) (bool, UploadData, error) {
+	var data UploadData
+	if err := database.Get(ctx, uploadKey(hash), &data); err != nil {
+		if err == datastore.ErrNoSuchEntity {
+			return false, UploadData{}, nil
+		}
+		return false, UploadData{}, err
+	}
+	return true, data, nil
+}
+
+func Share(ctx context.Context, database services.Database, hash string) (bool, ShareState, error) {
+	var data ShareState
+	if err := database.Get(ctx, shareStateKey(hash), &data); err != nil {
+		if err == datastore.ErrNoSuchEntity {
+			return false, ShareState{}, nil
+		}
+		return false, ShareState{}, err
+	}
+	return true, data, nil
+}
+
+// UpdateShareState reads the ShareState for hash, calls update with it, and stores the result. found is
+// false if there's no ShareState for the hash. If update returns an error, nothing is stored. Shares are
+// updated by concurrent requests (e.g. a report while an admin blocks the share), so the update is in a
+// transaction if the database supports them (see NewDatastoreDatabase), or holds a lock otherwise.
+func UpdateShareState(ctx context.Context, database services.Database, hash string, update func(state *ShareState, found bool) error) error {
+	if t, ok := database.(transactor); ok {
+		return t.updateShareState(ctx, hash, update)
+	}
+	updates.Lock()
+	defer updates.Unlock()
+	found, state, err := Share(ctx, database, hash)
+	if err != nil {
+		return err
+	}
+	if err := update(&state, found); err != nil {
+		return err
+	}
+	return StoreShareState(ctx, database, state)
+}
+
+// updates is held by UpdateShareState for databases without transactions, which only run in a single
+// server (e.g. in local mode).
+var updates sync.Mutex
+
+type transactor interface {
+	updateShareState(ctx context.Context, hash string, update func(state *ShareState, found bool) error) error
+}
+
+// NewDatastoreDatabase wraps the services.Database for the Google Cloud Datastore, so UpdateShareState
+// can use transactions.
+func NewDatastoreDatabase(database services.Database, client *datastore.Client) services.Database {
+	return &datastoreDatabase{Database: database, client: client}
+}
+
+type datastoreDatabase struct {
+	services.Database
+	client *datastore.Client
+}
+
+func (d *datastoreDatabase) updateShareState(ctx context.Context, hash string, update func(state *ShareState, found bool) error) error {
+	_, err := d.client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		var state ShareState
+		found := true
+		if err := tx.Get(shareStateKey(hash), &state); err != nil {
+			if err != datastore.ErrNoSuchEntity {
+				return err
+			}
+			found = false
+		}
+		if err := update(&state, found); err != nil {
+			return err
+		}
+		_, err := tx.Put(shareStateKey(hash), &state)
+		return err
+	})
+	return err
+}
+
+// Owner gets the ShareOwner for a delete token. token is the hex encoded sha256 of the delete token.
+func Owner(ctx context.Context, database services.Database, token string) (bool, ShareOwner, error) {
+	var data ShareOwner
+	if err := database.Get(ctx, shareOwnerKey(token), &data); err != nil {
+		if err == datastore.ErrNoSuchEntity {
+			return false, ShareOwner{}, nil
+		}
+		return false, ShareOwner{}, err
+	}
+	return true, data, nil
+}
+
+// Lister lists the paths of all the stored packages, and the reported and expired shares.
+// services.Database has no queries, so this is implemented separately for each database.
+type Lister interface {
+	ListPackages(ctx context.Context) ([]string, error)
+	ListReportedShares(ctx context.Context) ([]ShareState, error)
+	ListExpiredShares(ctx context.Context, now time.Time) ([]ShareState, error)
+}
+
+// NewDatastoreLister returns a Lister for the Google Cloud Datastore.
+func NewDatastoreLister(client *datastore.Client) Lister {
+	return &datastoreLister{client: client}
+}
+
+type datastoreLister struct {
+	client *datastore.Client
+}
+
+func (d *datastoreLister) ListPackages(ctx context.Context) ([]string, error) {
+	keys, err := d.client.GetAll(ctx, datastore.NewQuery(config.PackageKind).KeysOnly(), nil)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, key := range keys {
+		paths = append(paths, key.Name)
+	}
+	return paths, nil
+}
+
+// ListReportedShares returns the shares with the most abuse reports that haven't been blocked or
+// deleted. The query needs the composite index in server/main/index.yaml.
+func (d *datastoreLister) ListReportedShares(ctx context.Context) ([]ShareState, error) {
+	var states []ShareState
+	q := datastore.NewQuery(config.ShareStateKind).
+		Filter("Blocked =", false).
+		Filter("Deleted =", false).
+		Filter("ReportCount >", 0).
+		Order("-ReportCount").
+		Limit(100)
+	if _, err := d.client.GetAll(ctx, q, &states); err != nil {
+		return nil, err
+	}
+	return states, nil
+}
+
+// ListExpiredShares returns the shares that expired before now and haven't been deleted. The query
+// needs the composite index in server/main/index.yaml.
+func (d *datastoreLister) ListExpiredShares(ctx context.Context, now time.Time) ([]ShareState, error) {
+	var states []ShareState
+	q := datastore.NewQuery(config.ShareStateKind).
+		Filter("Deleted =", false).
+		Filter("Expires >", time.Time{}).
+		Filter("Expires <", now)
+	if _, err := d.client.GetAll(ctx, q, &states); err != nil {
+		return nil, err
+	}
+	return states, nil
+}
+
 func errorKey() *datastore.Key {
 	return datastore.IncompleteKey(config.ErrorKind, nil)
 }
@@ -146,3 +394,19 @@ func shareKey() *datastore.Key {
 func packageKey(path string) *datastore.Key {
 	return datastore.NameKey(config.PackageKind, path, nil)
 }
+
+func deployIndexKey(index string) *datastore.Key {
+	return datastore.NameKey(config.DeployIndexKind, index, nil)
+}
+
+func uploadKey(hash string) *datastore.Key {
+	return datastore.NameKey(config.UploadKind, hash, nil)
+}
+
+func shareStateKey(hash string) *datastore.Key {
+	return datastore.NameKey(config.ShareStateKind, hash, nil)
+}
+
+func shareOwnerKey(token string) *datastore.Key {
+	return datastore.NameKey(config.ShareOwnerKind, token, nil)
+}
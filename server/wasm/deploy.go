@@ -29,28 +29,31 @@ func (h *Handler) DeployQuery(ctx context.Context, info messages.DeployQuery, re
 
 	var m sync.Mutex
 	var required []messages.DeployFileKey
+	var outer error
 	wg := &sync.WaitGroup{}
 
 	for _, file := range info.Files {
 		file := file
-		var outer error
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 			bucket, name, _ := details(file.Type, file.Hash)
 			exists, err := h.Fileserver.Exists(ctx, bucket, name)
+			m.Lock()
+			defer m.Unlock()
 			if err != nil {
 				outer = err
 				return
 			}
 			if !exists {
-				m.Lock()
 				required = append(required, file)
-				m.Unlock()
 			}
 		}()
 	}
 	wg.Wait()
+	if outer != nil {
+		return outer
+	}
 
 	send(messages.DeployQueryResponse{Required: required})
 